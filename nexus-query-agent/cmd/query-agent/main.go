@@ -1,103 +1,304 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"nexus-query-agent/internal/config"
 	"nexus-query-agent/internal/connection"
 	"nexus-query-agent/internal/executor"
+	"nexus-query-agent/internal/health"
+	"nexus-query-agent/internal/httpserver"
+	"nexus-query-agent/internal/logging"
+	"nexus-query-agent/internal/metrics"
 	"nexus-query-agent/internal/models"
+	"nexus-query-agent/internal/scheduler"
+	"nexus-query-agent/internal/version"
 )
 
 func main() {
-	// Parse flags
-	configPath := flag.String("config", "config/config.yml", "Path to config file")
+	// Parse flags; every config setting also has its own override flag
+	configPath := flag.String("config", config.DefaultPath("config/config.yml"), "Path to config file (env "+config.ConfigPathEnv+")")
+	overrides := config.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	log.Println("===========================================")
-	log.Println("       Nexus Query Agent Starting          ")
-	log.Println("===========================================")
-
 	// Load configuration
-	cfg, err := config.Load(*configPath)
+	cfg, err := config.Load(*configPath, overrides)
+	if err != nil {
+		slog.Error("Failed to load config", "path", *configPath, "error", err)
+		os.Exit(1)
+	}
+
+	// Structured logger from logging.level and logging.format; the standard
+	// log package (used by drivers) is routed through it as well
+	logger, err := logging.New(cfg.Logging, os.Stdout)
 	if err != nil {
-		log.Fatalf("ERROR: Failed to load config: %v", err)
+		slog.Error("Invalid logging config", "error", err)
+		os.Exit(1)
 	}
+	slog.SetDefault(logger)
+
+	build := version.Build()
+	logger.Info("Nexus Query Agent starting",
+		"version", build.Version, "commit", build.Commit,
+		"agent_id", cfg.Agent.ID, "agent_name", cfg.Agent.Name, "core_url", cfg.Nexus.CoreURL)
 
-	log.Printf("INFO: Agent ID: %s", cfg.Agent.ID)
-	log.Printf("INFO: Agent Name: %s", cfg.Agent.Name)
-	log.Printf("INFO: Nexus Core URL: %s", cfg.Nexus.CoreURL)
+	// Connection pools are shared across requests, keyed by datasource
+	pools := executor.NewPoolManager(&cfg.Limits, logger)
 
 	// Create Nexus client
-	client := connection.NewNexusClient(cfg)
+	client := connection.NewNexusClient(cfg, logger)
+	client.Build = &build
+	client.Capabilities = executor.AgentCapabilities(&cfg.Limits)
+
+	// Requests run on a bounded worker pool - connection details arrive per-request
+	sched := scheduler.New(cfg.Limits.MaxConcurrentQueries, cfg.Limits.MaxQueuedQueries, func(ctx context.Context, req *models.QueryRequest) {
+		handleQueryRequest(ctx, client, cfg, pools, logger, req)
+	})
+
+	metrics.RegisterQueueDepth(sched.QueueDepth)
 
-	// Set query handler - connections are now dynamic per-request
 	client.OnQueryRequest = func(req *models.QueryRequest) {
-		handleQueryRequest(client, cfg, req)
+		if err := sched.Submit(req); err != nil {
+			queryType := req.QueryType
+			if queryType == "" {
+				queryType = "select"
+			}
+			metrics.QueriesTotal.WithLabelValues(queryType, "rejected").Inc()
+			logger.Warn("Rejecting request", "request_id", req.RequestID, "datasource_id", req.Datasource.ID, "error", err)
+			code := "AGENT_BUSY"
+			if errors.Is(err, scheduler.ErrDuplicateRequest) {
+				code = "DUPLICATE_REQUEST"
+			}
+			client.SendError(req.RequestID, code, err.Error())
+		}
+	}
+
+	client.OnCancelQuery = func(requestID string) {
+		switch sched.Cancel(requestID) {
+		case scheduler.CancelledQueued:
+			logger.Info("Request cancelled before it started", "request_id", requestID)
+			client.SendCancelled(requestID)
+		case scheduler.CancelledRunning:
+			// handleQueryRequest replies once the executor aborts
+			logger.Info("Cancelling running request", "request_id", requestID)
+		default:
+			logger.Warn("Cancel for unknown request (already finished?)", "request_id", requestID)
+		}
 	}
 
-	// Connect to Nexus Core
-	if err := client.Connect(); err != nil {
-		log.Printf("ERROR: Failed to connect to Nexus Core: %v", err)
-		log.Println("INFO: Will retry connection...")
-		go client.Reconnect()
+	// Optional local HTTP listener for health checks and metrics
+	var httpServer *httpserver.Server
+	if cfg.HTTP.Listen != "" {
+		var probePools *executor.PoolManager
+		if cfg.HTTP.ProbeDatasources {
+			probePools = pools
+		}
+		checker := health.NewChecker(client, sched.Running, probePools)
+
+		httpServer = httpserver.New(cfg.HTTP.Listen, logger)
+		httpServer.Handle("/healthz", http.HandlerFunc(checker.Healthz))
+		httpServer.Handle("/readyz", http.HandlerFunc(checker.Readyz))
+		if cfg.HTTP.Metrics {
+			httpServer.Handle("/metrics", metrics.Handler())
+		}
+		httpServer.Start()
 	}
 
-	// Wait for termination signal
+	// Connect to Nexus Core, reconnecting automatically whenever the connection drops
+	runErr := make(chan error, 1)
+	go func() { runErr <- client.Run() }()
+
+	// Wait for termination signal, or a fatal registration failure
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	log.Println("INFO: Query Agent is running. Press Ctrl+C to stop.")
+	logger.Info("Query Agent is running. Press Ctrl+C to stop.")
 
-	<-sigChan
+	exitCode := 0
+	select {
+	case <-sigChan:
+	case err := <-runErr:
+		logger.Error("Giving up on Nexus Core", "error", err)
+		exitCode = 1
+	}
 
-	log.Println("INFO: Shutting down...")
+	logger.Info("Shutting down...")
 	client.Close()
-	log.Println("INFO: Shutdown complete")
+	sched.Stop()
+	pools.Close()
+	if httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		httpServer.Shutdown(shutdownCtx)
+		cancel()
+	}
+	logger.Info("Shutdown complete")
+	os.Exit(exitCode)
 }
 
 // handleQueryRequest processes incoming query requests with dynamic connections
-func handleQueryRequest(client *connection.NexusClient, cfg *config.Config, req *models.QueryRequest) {
-	log.Printf("INFO: Processing %s request %s for datasource %s:%d",
-		req.QueryType, req.RequestID, req.Datasource.Host, req.Datasource.Port)
+func handleQueryRequest(ctx context.Context, client *connection.NexusClient, cfg *config.Config, pools *executor.PoolManager, logger *slog.Logger, req *models.QueryRequest) {
+	queryType := req.QueryType
+	if queryType == "" {
+		queryType = "select" // Default to SELECT for backward compatibility
+	}
+
+	// Every log line of this request carries its identifiers
+	logger = logger.With(
+		"request_id", req.RequestID,
+		"datasource_id", req.Datasource.ID,
+		"datasource_type", req.Datasource.Type,
+		"query_type", queryType,
+	)
+	ctx = logging.WithContext(ctx, logger)
+
+	logger.Info("Processing request", "host", req.Datasource.Host, "port", req.Datasource.Port)
+	startTime := time.Now()
+
+	metrics.InFlight.Inc()
+	defer metrics.InFlight.Dec()
+
+	// Every return path sets the outcome recorded in queries_total
+	outcome := "error"
+	defer func() { metrics.QueriesTotal.WithLabelValues(queryType, outcome).Inc() }()
+
+	if cfg.Limits.DisableDML && executor.IsWrite(queryType) {
+		client.SendError(req.RequestID, "DML_DISABLED", queryType+" requests are disabled on this agent")
+		return
+	}
 
 	// Create executor based on datasource type
-	exec, err := executor.NewExecutor(req.Datasource.Type, &cfg.Limits)
+	exec, err := executor.NewExecutor(req.Datasource.Type, &cfg.Limits, pools)
 	if err != nil {
 		client.SendError(req.RequestID, "UNSUPPORTED_DATASOURCE", err.Error())
 		return
 	}
 
 	var result *models.QueryResult
+	var stream *connection.ResultStream
 
-	// Route based on query type
-	queryType := req.QueryType
-	if queryType == "" {
-		queryType = "select" // Default to SELECT for backward compatibility
-	}
+	// Bound every query by the configured (or requested) timeout
+	timeout := queryTimeout(cfg, req)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
+	// Route based on query type
 	switch queryType {
 	case "select":
-		// Execute SELECT query with pagination
-		result, err = exec.Execute(&req.Datasource, req.Query, req.Page, req.Limit)
+		var countMode executor.CountMode
+		countMode, err = executor.ParseCountMode(req.CountMode)
+		if err != nil {
+			client.SendError(req.RequestID, "INVALID_REQUEST", err.Error())
+			return
+		}
+
+		if !req.Stream {
+			// Execute SELECT query with pagination
+			result, err = exec.Execute(ctx, &req.Datasource, req.Query, req.Params, paging(cfg, req, countMode))
+			break
+		}
+
+		// Stream rows in chunks instead of building the whole page in memory
+		streamExec, ok := exec.(executor.StreamExecutor)
+		if !ok {
+			client.SendError(req.RequestID, "STREAMING_NOT_SUPPORTED", "Streaming not supported for "+req.Datasource.Type+" datasources")
+			return
+		}
+		stream = client.NewResultStream(req.RequestID, chunkSize(req.ChunkRows, cfg.Limits.StreamChunkRows), chunkSize(req.ChunkBytes, cfg.Limits.StreamChunkBytes))
+		result, err = streamExec.ExecuteStream(ctx, &req.Datasource, req.Query, req.Params, paging(cfg, req, countMode), stream)
 	case "insert", "update", "delete":
 		// Execute DML with transaction handling
-		if sapExec, ok := exec.(*executor.SapExecutor); ok {
-			result, err = sapExec.ExecuteDML(&req.Datasource, queryType, req.Query, req.Params)
-		} else {
-			client.SendError(req.RequestID, "DML_NOT_SUPPORTED", "DML operations only supported for SAP datasources")
+		dmlExec, ok := exec.(executor.DMLExecutor)
+		if !ok {
+			client.SendError(req.RequestID, "DML_NOT_SUPPORTED", "DML operations not supported for "+req.Datasource.Type+" datasources")
+			return
+		}
+		result, err = dmlExec.ExecuteDML(ctx, &req.Datasource, queryType, req.Query, req.Params)
+	case "transaction":
+		txExec, ok := exec.(executor.TransactionExecutor)
+		if !ok {
+			client.SendError(req.RequestID, "TRANSACTION_NOT_SUPPORTED", "Transactions not supported for "+req.Datasource.Type+" datasources")
 			return
 		}
+		if len(req.Statements) == 0 {
+			client.SendError(req.RequestID, "INVALID_REQUEST", "transaction requires at least one statement")
+			return
+		}
+		result, err = txExec.ExecuteTransaction(ctx, &req.Datasource, req.Statements)
+	case "bulk_insert", "upsert":
+		bulkExec, ok := exec.(executor.BulkExecutor)
+		if !ok {
+			client.SendError(req.RequestID, "BULK_NOT_SUPPORTED", "Bulk inserts not supported for "+req.Datasource.Type+" datasources")
+			return
+		}
+		if len(req.Rows) > cfg.Limits.MaxBulkRows {
+			client.SendError(req.RequestID, "INVALID_REQUEST", fmt.Sprintf("%s is limited to %d rows", queryType, cfg.Limits.MaxBulkRows))
+			return
+		}
+		result, err = bulkExec.ExecuteBulk(ctx, &req.Datasource, &executor.BulkInsert{
+			Schema:    req.Schema,
+			Table:     req.Table,
+			Columns:   req.Columns,
+			Rows:      req.Rows,
+			ChunkRows: chunkSize(req.ChunkRows, cfg.Limits.BulkChunkRows),
+			Atomic:    req.Atomic,
+			Upsert:    queryType == "upsert",
+			Key:       req.Key,
+		})
+	case "call":
+		procExec, ok := exec.(executor.ProcedureExecutor)
+		if !ok {
+			client.SendError(req.RequestID, "CALL_NOT_SUPPORTED", "Procedure calls not supported for "+req.Datasource.Type+" datasources")
+			return
+		}
+		if req.Procedure == "" {
+			client.SendError(req.RequestID, "INVALID_REQUEST", "call requires a procedure")
+			return
+		}
+		result, err = procExec.ExecuteCall(ctx, &req.Datasource, &executor.ProcedureCall{
+			Schema:    req.Schema,
+			Procedure: req.Procedure,
+			Args:      req.Args,
+		})
+	case "list_schemas", "list_tables", "list_views", "describe_table":
+		metaExec, ok := exec.(executor.MetadataExecutor)
+		if !ok {
+			client.SendError(req.RequestID, "METADATA_NOT_SUPPORTED", "Metadata requests not supported for "+req.Datasource.Type+" datasources")
+			return
+		}
+		if queryType == "describe_table" && req.Table == "" {
+			client.SendError(req.RequestID, "INVALID_REQUEST", "describe_table requires a table")
+			return
+		}
+		result, err = executeMetadata(ctx, metaExec, req, queryType)
 	default:
-		client.SendError(req.RequestID, "INVALID_QUERY_TYPE", "Query type must be: select, insert, update, or delete")
+		client.SendError(req.RequestID, "INVALID_QUERY_TYPE", "Query type must be: select, insert, update, delete, transaction, bulk_insert, upsert, call, list_schemas, list_tables, list_views, or describe_table")
 		return
 	}
 
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			outcome = "timeout"
+			logger.Error("Query timed out", "duration_ms", time.Since(startTime).Milliseconds(), "timeout", timeout.String())
+			client.SendError(req.RequestID, "QUERY_TIMEOUT", fmt.Sprintf("Query exceeded timeout of %s", timeout))
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			outcome = "cancelled"
+			logger.Info("Query cancelled", "duration_ms", time.Since(startTime).Milliseconds())
+			client.SendCancelled(req.RequestID)
+			return
+		}
+		logger.Error("Query failed", "duration_ms", time.Since(startTime).Milliseconds(), "error", err)
 		client.SendError(req.RequestID, "EXECUTION_ERROR", err.Error())
 		return
 	}
@@ -105,17 +306,101 @@ func handleQueryRequest(client *connection.NexusClient, cfg *config.Config, req
 	result.RequestID = req.RequestID
 
 	// Send result
-	if err := client.SendResult(result); err != nil {
-		log.Printf("ERROR: Failed to send result: %v", err)
+	if stream != nil {
+		err = stream.End(result)
+	} else {
+		err = client.SendResult(result)
+	}
+	if err != nil {
+		logger.Error("Failed to send result", "error", err)
 		return
 	}
 
+	if result.Success {
+		outcome = "success"
+	}
+
 	// Log appropriate message based on query type
-	if queryType == "select" {
-		log.Printf("INFO: Query %s completed in %dms, %d rows returned",
-			req.RequestID, result.ExecutionTimeMs, len(result.Data))
-	} else {
-		log.Printf("INFO: %s %s completed in %dms, %d rows affected",
-			queryType, req.RequestID, result.ExecutionTimeMs, result.AffectedRows)
+	switch {
+	case !result.Success:
+		logger.Warn("Query failed", "duration_ms", result.ExecutionTimeMs, "error", result.Error)
+	case stream != nil:
+		metrics.ObserveRows(&req.Datasource, stream.RowCount())
+		logger.Info("Query streamed", "duration_ms", result.ExecutionTimeMs, "rows", stream.RowCount())
+	case queryType == "select":
+		metrics.ObserveRows(&req.Datasource, len(result.Data))
+		logger.Info("Query completed", "duration_ms", result.ExecutionTimeMs, "rows", len(result.Data))
+	case queryType == "describe_table":
+		logger.Info("Table described", "duration_ms", result.ExecutionTimeMs, "columns", len(result.Columns))
+	case strings.HasPrefix(queryType, "list_"):
+		logger.Info("Catalog listed", "duration_ms", result.ExecutionTimeMs, "rows", len(result.Data))
+	case queryType == "bulk_insert":
+		logger.Info("Bulk insert completed", "duration_ms", result.ExecutionTimeMs,
+			"rows", len(req.Rows), "affected_rows", result.AffectedRows)
+	case queryType == "upsert":
+		logger.Info("Upsert completed", "duration_ms", result.ExecutionTimeMs,
+			"rows", len(req.Rows), "affected_rows", result.AffectedRows)
+	case queryType == "call":
+		logger.Info("Procedure called", "duration_ms", result.ExecutionTimeMs,
+			"result_sets", len(result.ResultSets), "out_params", len(result.OutParams),
+			"omitted_result_sets", result.OmittedResultSets)
+	case queryType == "transaction":
+		logger.Info("Transaction committed", "duration_ms", result.ExecutionTimeMs,
+			"statements", len(result.Statements), "affected_rows", result.AffectedRows)
+	default:
+		logger.Info("DML completed", "duration_ms", result.ExecutionTimeMs, "affected_rows", result.AffectedRows)
+	}
+}
+
+// paging builds the executor paging options of a SELECT request
+func paging(cfg *config.Config, req *models.QueryRequest, count executor.CountMode) executor.Paging {
+	p := executor.Paging{
+		Page:     req.Page,
+		Limit:    req.Limit,
+		Count:    count,
+		CountTTL: cfg.Limits.CountCacheTTL,
+		ReadOnly: cfg.Limits.ReadOnlySelect,
+	}
+	if len(req.OrderKey) > 0 {
+		p.Keyset = &executor.Keyset{
+			Columns: req.OrderKey,
+			Desc:    req.OrderDesc,
+			Cursor:  req.Cursor,
+		}
+	}
+	return p
+}
+
+// executeMetadata dispatches a catalog request to the executor
+func executeMetadata(ctx context.Context, exec executor.MetadataExecutor, req *models.QueryRequest, queryType string) (*models.QueryResult, error) {
+	switch queryType {
+	case "list_schemas":
+		return exec.ListSchemas(ctx, &req.Datasource, req.Page, req.Limit)
+	case "list_tables":
+		return exec.ListTables(ctx, &req.Datasource, req.Schema, req.Page, req.Limit)
+	case "list_views":
+		return exec.ListViews(ctx, &req.Datasource, req.Schema, req.Page, req.Limit)
+	default:
+		return exec.DescribeTable(ctx, &req.Datasource, req.Schema, req.Table)
+	}
+}
+
+// queryTimeout returns the timeout for a request. A per-request timeout may
+// shorten limits.query_timeout but never extend it.
+func queryTimeout(cfg *config.Config, req *models.QueryRequest) time.Duration {
+	timeout := cfg.Limits.QueryTimeout
+	if req.TimeoutMs > 0 {
+		if requested := time.Duration(req.TimeoutMs) * time.Millisecond; requested < timeout {
+			timeout = requested
+		}
+	}
+	return timeout
+}
+
+// chunkSize returns the requested chunk limit, or the configured one when unset or larger
+func chunkSize(requested, configured int) int {
+	if requested <= 0 || requested > configured {
+		return configured
 	}
+	return requested
 }
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -13,20 +15,26 @@ type Config struct {
 	Nexus   NexusConfig   `yaml:"nexus"`
 	Limits  LimitsConfig  `yaml:"limits"`
 	Logging LoggingConfig `yaml:"logging"`
+	HTTP    HTTPConfig    `yaml:"http"`
 }
 
 // AgentConfig represents agent identity
 type AgentConfig struct {
-	ID    string `yaml:"id"`
-	Name  string `yaml:"name"`
-	Token string `yaml:"token"`
+	ID        string `yaml:"id"`
+	Name      string `yaml:"name"`
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"token_file"` // Read the token from this file instead (e.g. a Docker secret)
 }
 
 // NexusConfig represents Nexus Core connection settings
 type NexusConfig struct {
-	CoreURL           string        `yaml:"core_url"`
-	ReconnectInterval time.Duration `yaml:"reconnect_interval"`
-	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	CoreURL              string        `yaml:"core_url"`
+	ReconnectInterval    time.Duration `yaml:"reconnect_interval"`     // Initial reconnect backoff
+	MaxReconnectInterval time.Duration `yaml:"max_reconnect_interval"` // Backoff ceiling
+	HeartbeatInterval    time.Duration `yaml:"heartbeat_interval"`
+	RegisterTimeout      time.Duration `yaml:"register_timeout"`     // Wait this long for the registration ack
+	AuthRetryInterval    time.Duration `yaml:"auth_retry_interval"`  // Retry interval after a rejected registration
+	ExitOnAuthFailure    bool          `yaml:"exit_on_auth_failure"` // Exit instead of retrying a rejected registration
 }
 
 // LimitsConfig represents query limits
@@ -34,6 +42,32 @@ type LimitsConfig struct {
 	MaxRows              int           `yaml:"max_rows"`
 	QueryTimeout         time.Duration `yaml:"query_timeout"`
 	MaxConcurrentQueries int           `yaml:"max_concurrent_queries"`
+	MaxQueuedQueries     int           `yaml:"max_queued_queries"`
+
+	// Streamed SELECT results
+	MaxStreamRows    int `yaml:"max_stream_rows"`
+	StreamChunkRows  int `yaml:"stream_chunk_rows"`
+	StreamChunkBytes int `yaml:"stream_chunk_bytes"`
+
+	// Connection pooling per datasource
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	PoolIdleTimeout time.Duration `yaml:"pool_idle_timeout"`
+
+	// Lifetime of total row counts cached for count_mode "cached"
+	CountCacheTTL time.Duration `yaml:"count_cache_ttl"`
+
+	// LOB values (SAP HANA CLOB/NCLOB/BLOB) larger than this are truncated
+	MaxLOBBytes int `yaml:"max_lob_bytes"`
+
+	// Bulk inserts
+	MaxBulkRows   int `yaml:"max_bulk_rows"`   // Rows per bulk_insert request
+	BulkChunkRows int `yaml:"bulk_chunk_rows"` // Rows per statement execution
+
+	// Query guard
+	ReadOnlySelect bool `yaml:"read_only_select"` // Run SELECTs in read-only transactions
+	DisableDML     bool `yaml:"disable_dml"`      // Reject every query type that can write
 }
 
 // LoggingConfig represents logging settings
@@ -42,8 +76,17 @@ type LoggingConfig struct {
 	Format string `yaml:"format"`
 }
 
-// Load reads configuration from a YAML file
-func Load(path string) (*Config, error) {
+// HTTPConfig represents the local HTTP listener (/healthz, /readyz, /metrics)
+type HTTPConfig struct {
+	Listen           string `yaml:"listen"`            // e.g. ":9090"; empty disables the listener
+	Metrics          bool   `yaml:"metrics"`           // Serve Prometheus metrics at /metrics
+	ProbeDatasources bool   `yaml:"probe_datasources"` // /readyz also pings pooled datasources
+}
+
+// Load reads configuration from a YAML file and applies environment and
+// flag overrides on top of it (see EnvPrefix for the precedence order).
+// flags may be nil.
+func Load(path string, flags *Flags) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -54,6 +97,25 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	// token_file is read at the stage that sets it, so that a token from a
+	// later stage still overrides a token_file from an earlier one
+	tokenFile, err := readTokenFile(&cfg, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if tokenFile, err = readTokenFile(&cfg, tokenFile); err != nil {
+		return nil, err
+	}
+	if err := flags.apply(&cfg); err != nil {
+		return nil, err
+	}
+	if _, err = readTokenFile(&cfg, tokenFile); err != nil {
+		return nil, err
+	}
+
 	// Set defaults
 	if cfg.Limits.MaxRows == 0 {
 		cfg.Limits.MaxRows = 100000
@@ -64,12 +126,120 @@ func Load(path string) (*Config, error) {
 	if cfg.Limits.MaxConcurrentQueries == 0 {
 		cfg.Limits.MaxConcurrentQueries = 10
 	}
+	if cfg.Limits.MaxQueuedQueries == 0 {
+		cfg.Limits.MaxQueuedQueries = 100
+	}
+	if cfg.Limits.MaxStreamRows == 0 {
+		cfg.Limits.MaxStreamRows = 1000000
+	}
+	if cfg.Limits.StreamChunkRows == 0 {
+		cfg.Limits.StreamChunkRows = 1000
+	}
+	if cfg.Limits.StreamChunkBytes == 0 {
+		cfg.Limits.StreamChunkBytes = 1024 * 1024
+	}
+	if cfg.Limits.MaxOpenConns == 0 {
+		cfg.Limits.MaxOpenConns = cfg.Limits.MaxConcurrentQueries
+	}
+	if cfg.Limits.MaxIdleConns == 0 {
+		cfg.Limits.MaxIdleConns = 2
+	}
+	if cfg.Limits.ConnMaxIdleTime == 0 {
+		cfg.Limits.ConnMaxIdleTime = 5 * time.Minute
+	}
+	if cfg.Limits.PoolIdleTimeout == 0 {
+		cfg.Limits.PoolIdleTimeout = 30 * time.Minute
+	}
+	if cfg.Limits.CountCacheTTL == 0 {
+		cfg.Limits.CountCacheTTL = 5 * time.Minute
+	}
+	if cfg.Limits.MaxLOBBytes == 0 {
+		cfg.Limits.MaxLOBBytes = 1024 * 1024
+	}
+	if cfg.Limits.MaxBulkRows == 0 {
+		cfg.Limits.MaxBulkRows = 100000
+	}
+	if cfg.Limits.BulkChunkRows == 0 {
+		cfg.Limits.BulkChunkRows = 1000
+	}
 	if cfg.Nexus.ReconnectInterval == 0 {
 		cfg.Nexus.ReconnectInterval = 5 * time.Second
 	}
+	if cfg.Nexus.MaxReconnectInterval == 0 {
+		cfg.Nexus.MaxReconnectInterval = 2 * time.Minute
+	}
+	if cfg.Nexus.MaxReconnectInterval < cfg.Nexus.ReconnectInterval {
+		cfg.Nexus.MaxReconnectInterval = cfg.Nexus.ReconnectInterval
+	}
 	if cfg.Nexus.HeartbeatInterval == 0 {
 		cfg.Nexus.HeartbeatInterval = 30 * time.Second
 	}
+	if cfg.Nexus.RegisterTimeout == 0 {
+		cfg.Nexus.RegisterTimeout = 30 * time.Second
+	}
+	if cfg.Nexus.AuthRetryInterval == 0 {
+		cfg.Nexus.AuthRetryInterval = 5 * time.Minute
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
+
+// readTokenFile replaces agent.token with the trimmed contents of
+// agent.token_file if the last stage changed token_file from prev. It returns
+// the current token_file.
+func readTokenFile(cfg *Config, prev string) (string, error) {
+	path := cfg.Agent.TokenFile
+	if path == "" || path == prev {
+		return path, nil
+	}
+	token, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading agent token file: %w", err)
+	}
+	cfg.Agent.Token = strings.TrimSpace(string(token))
+	return path, nil
+}
+
+// validate rejects settings that defaults do not cover and the agent cannot
+// run with
+func (cfg *Config) validate() error {
+	if cfg.Limits.MaxConcurrentQueries < 0 {
+		return fmt.Errorf("limits.max_concurrent_queries must be positive, got %d", cfg.Limits.MaxConcurrentQueries)
+	}
+	if cfg.Limits.MaxQueuedQueries < 0 {
+		return fmt.Errorf("limits.max_queued_queries must be positive, got %d", cfg.Limits.MaxQueuedQueries)
+	}
+	if cfg.Limits.MaxRows < 0 {
+		return fmt.Errorf("limits.max_rows must be positive, got %d", cfg.Limits.MaxRows)
+	}
+	if cfg.Limits.MaxStreamRows < 0 {
+		return fmt.Errorf("limits.max_stream_rows must be positive, got %d", cfg.Limits.MaxStreamRows)
+	}
+	if cfg.Limits.StreamChunkRows < 0 {
+		return fmt.Errorf("limits.stream_chunk_rows must be positive, got %d", cfg.Limits.StreamChunkRows)
+	}
+	if cfg.Limits.MaxOpenConns < 0 {
+		return fmt.Errorf("limits.max_open_conns must be positive, got %d", cfg.Limits.MaxOpenConns)
+	}
+	if cfg.Limits.MaxLOBBytes < 0 {
+		return fmt.Errorf("limits.max_lob_bytes must be positive, got %d", cfg.Limits.MaxLOBBytes)
+	}
+	// The pool reaper ticks at half the idle timeout
+	if cfg.Limits.PoolIdleTimeout/2 <= 0 {
+		return fmt.Errorf("limits.pool_idle_timeout must be at least 2ns, got %s", cfg.Limits.PoolIdleTimeout)
+	}
+	if cfg.Nexus.ReconnectInterval < 0 {
+		return fmt.Errorf("nexus.reconnect_interval must be positive, got %s", cfg.Nexus.ReconnectInterval)
+	}
+	if cfg.Nexus.HeartbeatInterval < 0 {
+		return fmt.Errorf("nexus.heartbeat_interval must be positive, got %s", cfg.Nexus.HeartbeatInterval)
+	}
+	if cfg.Limits.BulkChunkRows < 0 {
+		return fmt.Errorf("limits.bulk_chunk_rows must be positive, got %d", cfg.Limits.BulkChunkRows)
+	}
+	return nil
+}
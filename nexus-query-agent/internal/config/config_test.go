@@ -0,0 +1,160 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// load runs Load with args parsed into a fresh flag set
+func load(t *testing.T, yaml string, args ...string) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return Load(writeFile(t, "config.yaml", yaml), flags)
+}
+
+func TestPrecedence(t *testing.T) {
+	yaml := "limits:\n  max_rows: 10\n  max_stream_rows: 10\n  stream_chunk_rows: 10\n"
+	t.Setenv("NEXUS_AGENT_LIMITS_MAX_ROWS", "20")
+	t.Setenv("NEXUS_AGENT_LIMITS_MAX_STREAM_ROWS", "20")
+
+	cfg, err := load(t, yaml, "-limits.max_rows=30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		setting   string
+		got, want int
+	}{
+		{"limits.max_rows (flag)", cfg.Limits.MaxRows, 30},
+		{"limits.max_stream_rows (env)", cfg.Limits.MaxStreamRows, 20},
+		{"limits.stream_chunk_rows (yaml)", cfg.Limits.StreamChunkRows, 10},
+		{"limits.max_bulk_rows (default)", cfg.Limits.MaxBulkRows, 100000},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.setting, tt.got, tt.want)
+		}
+	}
+}
+
+func TestTokenPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "yaml-token", "from-yaml-file\n")
+	flagFile := writeFile(t, "flag-token", "  from-flag-file  ")
+
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{
+			name: "yaml token",
+			yaml: "agent:\n  token: from-yaml\n",
+			want: "from-yaml",
+		},
+		{
+			name: "yaml token_file over yaml token",
+			yaml: "agent:\n  token: from-yaml\n  token_file: " + yamlFile + "\n",
+			want: "from-yaml-file",
+		},
+		{
+			name: "env token over yaml token_file",
+			yaml: "agent:\n  token_file: " + yamlFile + "\n",
+			env:  map[string]string{"NEXUS_AGENT_AGENT_TOKEN": "from-env"},
+			want: "from-env",
+		},
+		{
+			name: "env token_file over yaml token",
+			yaml: "agent:\n  token: from-yaml\n",
+			env:  map[string]string{"NEXUS_AGENT_AGENT_TOKEN_FILE": yamlFile},
+			want: "from-yaml-file",
+		},
+		{
+			name: "flag token_file over env token",
+			yaml: "agent:\n  token: from-yaml\n",
+			env:  map[string]string{"NEXUS_AGENT_AGENT_TOKEN": "from-env"},
+			args: []string{"-agent.token_file=" + flagFile},
+			want: "from-flag-file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := load(t, tt.yaml, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Agent.Token != tt.want {
+				t.Errorf("agent.token = %q, want %q", cfg.Agent.Token, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenHasNoFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	BindFlags(fs)
+	if fs.Lookup("agent.token") != nil {
+		t.Error("agent.token is registered as a flag")
+	}
+	if fs.Lookup("agent.token_file") == nil {
+		t.Error("agent.token_file is not registered as a flag")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		setting string
+		value   string
+	}{
+		{"limits.max_rows", "-1"},
+		{"limits.max_concurrent_queries", "-1"},
+		{"limits.max_queued_queries", "-1"},
+		{"limits.max_stream_rows", "-1"},
+		{"limits.stream_chunk_rows", "-1"},
+		{"limits.max_open_conns", "-1"},
+		{"limits.max_lob_bytes", "-1"},
+		{"limits.bulk_chunk_rows", "-1"},
+		{"limits.pool_idle_timeout", "-1m"},
+		{"limits.pool_idle_timeout", "1ns"},
+		{"nexus.reconnect_interval", "-1s"},
+		{"nexus.heartbeat_interval", "-1s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.setting+"="+tt.value, func(t *testing.T) {
+			_, err := load(t, "", "-"+tt.setting+"="+tt.value)
+			if err == nil {
+				t.Fatalf("Load with %s=%s = nil error", tt.setting, tt.value)
+			}
+			if !strings.Contains(err.Error(), tt.setting) {
+				t.Errorf("error %q does not name %s", err, tt.setting)
+			}
+		})
+	}
+
+	if _, err := load(t, "", "-limits.pool_idle_timeout=2ns"); err != nil {
+		t.Errorf("Load with limits.pool_idle_timeout=2ns = %v", err)
+	}
+}
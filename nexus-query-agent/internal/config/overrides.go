@@ -0,0 +1,186 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is prepended to every environment variable override.
+//
+// Every setting can be overridden without editing the YAML file. The name of
+// an override is derived from the setting's YAML path:
+//
+//	YAML path       limits.max_rows
+//	flag            -limits.max_rows=500
+//	environment     NEXUS_AGENT_LIMITS_MAX_ROWS=500
+//
+// Precedence, highest first:
+//
+//  1. command-line flags
+//  2. environment variables
+//  3. the YAML config file
+//  4. built-in defaults
+//
+// agent.token_file, when set, replaces agent.token with the trimmed
+// contents of that file (for example a Docker secret) at the same level:
+// NEXUS_AGENT_AGENT_TOKEN still overrides a token_file from the YAML file.
+//
+// agent.token has no flag, since command lines are visible to every user of
+// the host (ps); pass it via the environment or -agent.token_file instead.
+const EnvPrefix = "NEXUS_AGENT_"
+
+// ConfigPathEnv selects the config file when -config is not given
+const ConfigPathEnv = EnvPrefix + "CONFIG"
+
+// DefaultPath returns the config file path from the environment, or fallback
+func DefaultPath(fallback string) string {
+	if path := os.Getenv(ConfigPathEnv); path != "" {
+		return path
+	}
+	return fallback
+}
+
+// Flags holds command-line overrides registered by BindFlags
+type Flags struct {
+	fs     *flag.FlagSet
+	values map[string]*string
+}
+
+// secretSettings have no flag so that they never show up in ps output
+var secretSettings = map[string]bool{
+	"agent.token": true,
+}
+
+// BindFlags registers one flag per config setting, except secretSettings, on
+// fs. Call it before fs.Parse and pass the result to Load.
+func BindFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs, values: make(map[string]*string)}
+
+	walkFields(reflect.ValueOf(&Config{}).Elem(), "", func(path string, _ reflect.Value) {
+		if secretSettings[path] {
+			return
+		}
+		usage := fmt.Sprintf("Override %s (env %s)", path, envName(path))
+		f.values[path] = fs.String(path, "", usage)
+	})
+
+	return f
+}
+
+// applyEnv overrides settings from NEXUS_AGENT_* environment variables
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	var firstErr error
+	walkFields(reflect.ValueOf(cfg).Elem(), "", func(path string, field reflect.Value) {
+		raw, ok := lookup(envName(path))
+		if !ok || firstErr != nil {
+			return
+		}
+		if err := setField(field, raw); err != nil {
+			firstErr = fmt.Errorf("%s: %w", envName(path), err)
+		}
+	})
+	return firstErr
+}
+
+// apply overrides settings from flags that were set on the command line
+func (f *Flags) apply(cfg *Config) error {
+	if f == nil {
+		return nil
+	}
+
+	set := make(map[string]bool)
+	f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+
+	var firstErr error
+	walkFields(reflect.ValueOf(cfg).Elem(), "", func(path string, field reflect.Value) {
+		if !set[path] || firstErr != nil {
+			return
+		}
+		if err := setField(field, *f.values[path]); err != nil {
+			firstErr = fmt.Errorf("-%s: %w", path, err)
+		}
+	})
+	return firstErr
+}
+
+// envName maps a YAML path such as limits.max_rows to NEXUS_AGENT_LIMITS_MAX_ROWS
+func envName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// walkFields calls fn for every leaf setting of a config struct with its YAML path
+func walkFields(v reflect.Value, prefix string, fn func(path string, field reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			walkFields(field, path, fn)
+			continue
+		}
+		fn(path, field)
+	}
+}
+
+// setField parses raw into a config field of a supported kind
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported setting type %s", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package connection
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// backoff computes reconnect delays that double after every failed attempt,
+// up to max, with random jitter so that agents do not reconnect in lockstep
+type backoff struct {
+	initial  time.Duration
+	max      time.Duration
+	attempts int
+}
+
+// next returns the delay before the next attempt.
+// The delay is drawn uniformly from the upper half of the current interval.
+func (b *backoff) next() time.Duration {
+	interval := b.initial
+	for i := 0; i < b.attempts && interval < b.max; i++ {
+		interval *= 2
+	}
+	if interval > b.max {
+		interval = b.max
+	}
+	b.attempts++
+
+	half := interval / 2
+	return half + rand.N(half+1)
+}
+
+// reset starts over from the initial interval after a successful connection
+func (b *backoff) reset() {
+	b.attempts = 0
+}
@@ -2,39 +2,124 @@ package connection
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 
 	"nexus-query-agent/internal/config"
+	"nexus-query-agent/internal/metrics"
 	"nexus-query-agent/internal/models"
 )
 
+// errClientClosed is returned by connect when Close was called during the dial
+var errClientClosed = errors.New("client closed")
+
+// writeTimeout bounds a single message write, so a stalled peer fails the
+// send (and drops the connection) instead of blocking the sender forever
+const writeTimeout = 30 * time.Second
+
+// errNotConnected is returned by sends while there is no connection, so that
+// a streamed query aborts instead of dropping chunks
+var errNotConnected = errors.New("not connected to Nexus Core")
+
 // NexusClient manages WebSocket connection to Nexus Core
 type NexusClient struct {
 	config      *config.Config
+	logger      *slog.Logger
 	conn        *websocket.Conn
 	mu          sync.Mutex
+	writeMu     sync.Mutex // Serializes writes; held without mu so a slow write doesn't block State
 	isConnected bool
-	done        chan struct{}
+	state       RegistrationState
+	ack         chan models.RegisteredMessage // pending registration ack, if any
+	lastBeat    time.Time
+	stop        chan struct{}
+	stopOnce    sync.Once
 
-	// Handler for incoming query requests
+	// Handler for incoming query requests; called from the read loop, so it must not block
 	OnQueryRequest func(req *models.QueryRequest)
+
+	// Handler for cancel requests; called from the read loop, so it must not block
+	OnCancelQuery func(requestID string)
+
+	// Advertised in every register message; set before Run
+	Build        *models.BuildInfo
+	Capabilities *models.AgentCapabilities
 }
 
 // NewNexusClient creates a new Nexus client
-func NewNexusClient(cfg *config.Config) *NexusClient {
+func NewNexusClient(cfg *config.Config, logger *slog.Logger) *NexusClient {
 	return &NexusClient{
 		config: cfg,
-		done:   make(chan struct{}),
+		logger: logger,
+		state:  StateDisconnected,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Run keeps the agent connected to Nexus Core until Close is called.
+// Whenever the connection is lost it reconnects with exponential backoff and
+// jitter, registers again and resumes heartbeats. A rejected registration is
+// retried on its own, slower interval, or ends Run with ErrRegistrationRejected
+// when nexus.exit_on_auth_failure is set. Run returns nil after Close.
+func (c *NexusClient) Run() error {
+	b := &backoff{
+		initial: c.config.Nexus.ReconnectInterval,
+		max:     c.config.Nexus.MaxReconnectInterval,
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			metrics.Reconnects.Inc()
+		}
+
+		done, err := c.connect()
+		if err != nil {
+			if errors.Is(err, errClientClosed) {
+				return nil
+			}
+
+			var delay time.Duration
+			if errors.Is(err, ErrRegistrationRejected) {
+				if c.config.Nexus.ExitOnAuthFailure {
+					return err
+				}
+				delay = c.config.Nexus.AuthRetryInterval
+				c.logger.Error("Registration rejected by Nexus Core", "error", err)
+			} else {
+				delay = b.next()
+				c.logger.Error("Failed to connect to Nexus Core", "error", err)
+			}
+			c.logger.Info("Attempting to reconnect", "delay", delay.Round(time.Millisecond).String())
+
+			select {
+			case <-c.stop:
+				return nil
+			case <-time.After(delay):
+			}
+			continue
+		}
+
+		b.reset()
+
+		select {
+		case <-c.stop:
+			return nil
+		case <-done:
+			c.logger.Warn("Connection to Nexus Core lost")
+		}
 	}
 }
 
-// Connect establishes WebSocket connection to Nexus Core
-func (c *NexusClient) Connect() error {
-	log.Printf("INFO: Connecting to Nexus Core at %s", c.config.Nexus.CoreURL)
+// connect establishes a WebSocket connection to Nexus Core, registers, waits
+// for the acknowledgement and starts heartbeats. The returned channel is
+// closed when the connection is lost.
+func (c *NexusClient) connect() (<-chan struct{}, error) {
+	c.logger.Info("Connecting to Nexus Core", "url", c.config.Nexus.CoreURL)
 
 	// Create dialer with larger buffer sizes for large data transfers
 	dialer := websocket.Dialer{
@@ -45,29 +130,67 @@ func (c *NexusClient) Connect() error {
 
 	conn, _, err := dialer.Dial(c.config.Nexus.CoreURL, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Set connection settings for large messages
 	conn.SetReadLimit(50 * 1024 * 1024) // 50 MB max message size
 
+	ack := make(chan models.RegisteredMessage, 1)
+
 	c.mu.Lock()
+	select {
+	case <-c.stop:
+		// Closed while dialing
+		c.mu.Unlock()
+		conn.Close()
+		return nil, errClientClosed
+	default:
+	}
 	c.conn = conn
 	c.isConnected = true
+	c.state = StateRegistering
+	c.ack = ack
 	c.mu.Unlock()
 
-	log.Printf("INFO: Connected to Nexus Core")
+	c.logger.Info("Connected to Nexus Core")
+
+	// Each connection gets its own done channel, closed by its read loop
+	done := make(chan struct{})
+	go c.readLoop(conn, done)
 
-	// Send registration message
+	// Send registration message and wait for the ack
 	if err := c.register(); err != nil {
-		return err
+		c.dropConn(conn)
+		return nil, err
 	}
 
-	// Start goroutines
-	go c.readLoop()
-	go c.heartbeatLoop()
+	timer := time.NewTimer(c.config.Nexus.RegisterTimeout)
+	defer timer.Stop()
 
-	return nil
+	select {
+	case msg := <-ack:
+		if msg.Status != "ok" {
+			c.setState(StateRejected)
+			c.dropConn(conn)
+			return nil, fmt.Errorf("%w: %s", ErrRegistrationRejected, msg.Message)
+		}
+	case <-timer.C:
+		c.dropConn(conn)
+		return nil, errRegisterTimeout
+	case <-done:
+		return nil, errors.New("connection closed before registration was acknowledged")
+	case <-c.stop:
+		c.dropConn(conn)
+		return nil, errClientClosed
+	}
+
+	// handleMessage already set StateRegistered
+	c.logger.Info("Registered with Nexus Core")
+
+	go c.heartbeatLoop(done)
+
+	return done, nil
 }
 
 // register sends registration message to Nexus
@@ -78,53 +201,111 @@ func (c *NexusClient) register() error {
 		AgentName: c.config.Agent.Name,
 		AgentType: "query",
 		Token:     c.config.Agent.Token,
+
+		Build:        c.Build,
+		Capabilities: c.Capabilities,
+	}
+	if c.Build != nil {
+		msg.Version = c.Build.Version
 	}
 
 	return c.sendJSON(msg)
 }
 
-// readLoop handles incoming messages
-func (c *NexusClient) readLoop() {
-	defer c.Close()
+// readLoop handles incoming messages until the connection fails
+func (c *NexusClient) readLoop(conn *websocket.Conn, done chan struct{}) {
+	defer close(done)
+	defer c.dropConn(conn)
 
 	for {
-		select {
-		case <-c.done:
-			return
-		default:
-			_, message, err := c.conn.ReadMessage()
-			if err != nil {
-				log.Printf("ERROR: Read error: %v", err)
-				return
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-c.stop:
+			default:
+				c.logger.Error("Read error", "error", err)
 			}
+			return
+		}
+
+		c.handleMessage(message)
+	}
+}
+
+// dropConn closes conn and marks the client disconnected if conn is still current
+func (c *NexusClient) dropConn(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-			c.handleMessage(message)
+	if c.conn == conn {
+		c.conn = nil
+		c.isConnected = false
+		c.ack = nil
+		// A rejection stays visible to health checks until the next attempt
+		if c.state != StateRejected {
+			c.state = StateDisconnected
 		}
 	}
+	conn.Close()
 }
 
 // handleMessage processes incoming messages
 func (c *NexusClient) handleMessage(data []byte) {
 	var base models.BaseMessage
 	if err := json.Unmarshal(data, &base); err != nil {
-		log.Printf("ERROR: Failed to parse message: %v", err)
+		c.logger.Error("Failed to parse message", "error", err)
 		return
 	}
 
 	switch base.Type {
 	case models.MessageTypeRegistered:
 		var msg models.RegisteredMessage
-		if err := json.Unmarshal(data, &msg); err == nil {
-			log.Printf("INFO: Registration %s: %s", msg.Status, msg.Message)
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.logger.Error("Failed to parse registered message", "error", err)
+			return
+		}
+		c.logger.Info("Registration acknowledged", "status", msg.Status, "message", msg.Message)
+
+		c.mu.Lock()
+		ack := c.ack
+		c.ack = nil
+		conn := c.conn
+		// Mark the agent registered before the read loop dispatches the next
+		// message, so a query request right behind the ack is accepted
+		if ack != nil && msg.Status == "ok" {
+			c.state = StateRegistered
+		}
+		c.mu.Unlock()
+
+		if ack != nil {
+			ack <- msg
+		} else if msg.Status != "ok" && conn != nil {
+			// Registration revoked after the fact; reconnecting re-registers
+			c.setState(StateRejected)
+			conn.Close()
 		}
 
 	case models.MessageTypeQueryRequest:
 		var req models.QueryRequest
 		if err := json.Unmarshal(data, &req); err == nil {
-			log.Printf("INFO: Received query request: %s for %s:%d",
-				req.RequestID, req.Datasource.Host, req.Datasource.Port)
+			c.logger.Debug("Received query request",
+				"request_id", req.RequestID, "datasource_id", req.Datasource.ID, "query_type", req.QueryType)
+			if state := c.State(); state != StateRegistered {
+				c.logger.Warn("Refusing query request before registration", "request_id", req.RequestID, "state", state)
+				c.SendError(req.RequestID, "NOT_REGISTERED", "Agent is not registered with Nexus Core")
+				return
+			}
 			if c.OnQueryRequest != nil {
-				go c.OnQueryRequest(&req)
+				c.OnQueryRequest(&req)
+			}
+		}
+
+	case models.MessageTypeCancelQuery:
+		var msg models.CancelQueryMessage
+		if err := json.Unmarshal(data, &msg); err == nil {
+			c.logger.Info("Received cancel request", "request_id", msg.RequestID)
+			if c.OnCancelQuery != nil {
+				c.OnCancelQuery(msg.RequestID)
 			}
 		}
 
@@ -132,18 +313,18 @@ func (c *NexusClient) handleMessage(data []byte) {
 		c.sendJSON(models.BaseMessage{Type: models.MessageTypePong})
 
 	default:
-		log.Printf("DEBUG: Unknown message type: %s", base.Type)
+		c.logger.Debug("Unknown message type", "type", base.Type)
 	}
 }
 
-// heartbeatLoop sends periodic heartbeats
-func (c *NexusClient) heartbeatLoop() {
+// heartbeatLoop sends periodic heartbeats until done is closed
+func (c *NexusClient) heartbeatLoop(done <-chan struct{}) {
 	ticker := time.NewTicker(c.config.Nexus.HeartbeatInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-c.done:
+		case <-done:
 			return
 		case <-ticker.C:
 			msg := models.HeartbeatMessage{
@@ -152,8 +333,14 @@ func (c *NexusClient) heartbeatLoop() {
 				Timestamp: time.Now().Unix(),
 			}
 			if err := c.sendJSON(msg); err != nil {
-				log.Printf("ERROR: Heartbeat failed: %v", err)
+				metrics.HeartbeatFailures.Inc()
+				c.logger.Error("Heartbeat failed", "error", err)
+				continue
 			}
+
+			c.mu.Lock()
+			c.lastBeat = time.Now()
+			c.mu.Unlock()
 		}
 	}
 }
@@ -164,6 +351,16 @@ func (c *NexusClient) SendResult(result *models.QueryResult) error {
 	return c.sendJSON(result)
 }
 
+// SendCancelled sends the terminal result for a cancelled request
+func (c *NexusClient) SendCancelled(requestID string) error {
+	return c.sendJSON(models.QueryResult{
+		Type:      models.MessageTypeCancelled,
+		RequestID: requestID,
+		Success:   false,
+		Error:     "Query cancelled",
+	})
+}
+
 // SendError sends error message to Nexus
 func (c *NexusClient) SendError(requestID, code, message string) error {
 	msg := models.ErrorMessage{
@@ -175,20 +372,39 @@ func (c *NexusClient) SendError(requestID, code, message string) error {
 	return c.sendJSON(msg)
 }
 
-// sendJSON sends a JSON message
+// sendJSON sends a JSON message, or fails with errNotConnected while
+// disconnected. A failed or timed out write closes the connection so that
+// the read loop notices and Run reconnects.
 func (c *NexusClient) sendJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	conn := c.conn
+	c.mu.Unlock()
 
-	if c.conn == nil {
-		return nil
+	if conn == nil {
+		return errNotConnected
 	}
 
-	return c.conn.WriteJSON(v)
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		conn.Close()
+		return err
+	}
+	metrics.BytesSent.Add(float64(len(data)))
+	return nil
 }
 
-// Close closes the connection
+// Close stops Run and closes the connection
 func (c *NexusClient) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -197,35 +413,37 @@ func (c *NexusClient) Close() {
 		c.conn = nil
 	}
 	c.isConnected = false
-
-	select {
-	case <-c.done:
-		// Already closed
-	default:
-		close(c.done)
-	}
+	c.state = StateDisconnected
 }
 
-// IsConnected returns connection status
-func (c *NexusClient) IsConnected() bool {
+// State returns the registration state
+func (c *NexusClient) State() RegistrationState {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.isConnected
+	return c.state
 }
 
-// Reconnect attempts to reconnect with backoff
-func (c *NexusClient) Reconnect() {
-	for {
-		log.Printf("INFO: Attempting to reconnect in %s...", c.config.Nexus.ReconnectInterval)
-		time.Sleep(c.config.Nexus.ReconnectInterval)
+// setState updates the registration state
+func (c *NexusClient) setState(state RegistrationState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = state
+}
 
-		c.done = make(chan struct{})
-		if err := c.Connect(); err != nil {
-			log.Printf("ERROR: Reconnect failed: %v", err)
-			continue
-		}
+// Status is a snapshot of the connection state for health checks
+type Status struct {
+	Connected     bool
+	State         RegistrationState
+	LastHeartbeat time.Time
+}
 
-		log.Printf("INFO: Reconnected successfully")
-		return
+// Status returns the current connection state
+func (c *NexusClient) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Status{
+		Connected:     c.isConnected,
+		State:         c.state,
+		LastHeartbeat: c.lastBeat,
 	}
 }
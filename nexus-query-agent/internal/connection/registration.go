@@ -0,0 +1,25 @@
+package connection
+
+import "errors"
+
+// RegistrationState is the agent's registration status with Nexus Core
+type RegistrationState string
+
+const (
+	// StateDisconnected means there is no connection to Nexus Core
+	StateDisconnected RegistrationState = "disconnected"
+	// StateRegistering means the register message was sent and the ack is pending
+	StateRegistering RegistrationState = "registering"
+	// StateRegistered means Nexus Core acknowledged the registration
+	StateRegistered RegistrationState = "registered"
+	// StateRejected means Nexus Core refused the registration (e.g. bad token)
+	StateRejected RegistrationState = "rejected"
+)
+
+// ErrRegistrationRejected is returned when Nexus Core answers the register
+// message with an error status. Run returns it when nexus.exit_on_auth_failure
+// is set; otherwise it retries every nexus.auth_retry_interval.
+var ErrRegistrationRejected = errors.New("registration rejected by Nexus Core")
+
+// errRegisterTimeout is returned when no ack arrives within nexus.register_timeout
+var errRegisterTimeout = errors.New("timed out waiting for registration acknowledgement")
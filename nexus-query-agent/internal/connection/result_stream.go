@@ -0,0 +1,103 @@
+package connection
+
+import (
+	"encoding/json"
+
+	"nexus-query-agent/internal/models"
+)
+
+// ResultStream batches the rows of a streamed SELECT into
+// query_result_chunk messages of at most maxRows rows or maxBytes bytes
+type ResultStream struct {
+	client    *NexusClient
+	requestID string
+	maxRows   int
+	maxBytes  int
+
+	columns []models.ColumnInfo
+	rows    []json.RawMessage
+	size    int
+	seq     int
+	total   int
+}
+
+// NewResultStream creates a stream for a request
+func (c *NexusClient) NewResultStream(requestID string, maxRows, maxBytes int) *ResultStream {
+	return &ResultStream{
+		client:    c,
+		requestID: requestID,
+		maxRows:   maxRows,
+		maxBytes:  maxBytes,
+	}
+}
+
+// WriteColumns records the columns sent with the first chunk
+func (s *ResultStream) WriteColumns(columns []models.ColumnInfo) error {
+	s.columns = columns
+	return nil
+}
+
+// WriteRow buffers a row and sends a chunk once a limit is reached
+func (s *ResultStream) WriteRow(row map[string]any) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	s.rows = append(s.rows, data)
+	s.size += len(data)
+	s.total++
+
+	if len(s.rows) >= s.maxRows || s.size >= s.maxBytes {
+		return s.flush()
+	}
+	return nil
+}
+
+// RowCount returns the number of rows written so far
+func (s *ResultStream) RowCount() int {
+	return s.total
+}
+
+// End flushes buffered rows and sends query_result_end built from result.
+// At least one chunk is always sent so that Nexus receives the columns.
+func (s *ResultStream) End(result *models.QueryResult) error {
+	if result.Success && (len(s.rows) > 0 || s.seq == 0) {
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+
+	return s.client.sendJSON(models.QueryResultEnd{
+		Type:            models.MessageTypeResultEnd,
+		RequestID:       s.requestID,
+		Success:         result.Success,
+		Chunks:          s.seq,
+		RowCount:        s.total,
+		Pagination:      result.Pagination,
+		ExecutionTimeMs: result.ExecutionTimeMs,
+		Error:           result.Error,
+	})
+}
+
+// flush sends buffered rows as the next chunk
+func (s *ResultStream) flush() error {
+	chunk := models.QueryResultChunk{
+		Type:      models.MessageTypeResultChunk,
+		RequestID: s.requestID,
+		Seq:       s.seq,
+		Data:      s.rows,
+	}
+	if s.seq == 0 {
+		chunk.Columns = s.columns
+	}
+	if chunk.Data == nil {
+		chunk.Data = []json.RawMessage{}
+	}
+
+	s.seq++
+	s.rows = nil
+	s.size = 0
+
+	return s.client.sendJSON(chunk)
+}
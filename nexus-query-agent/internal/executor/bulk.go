@@ -0,0 +1,314 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"nexus-query-agent/internal/logging"
+	"nexus-query-agent/internal/models"
+)
+
+// BulkInsert is a column list and row matrix to insert (or upsert) into one table
+type BulkInsert struct {
+	Schema    string // Optional; defaults to the session's schema
+	Table     string
+	Columns   []string
+	Rows      [][]any
+	ChunkRows int  // Rows written per statement execution
+	Atomic    bool // All chunks in one transaction instead of one each
+
+	// Upsert updates rows matching Key instead of failing on them. An empty
+	// Key uses the table's primary key where the dialect can.
+	Upsert bool
+	Key    []string
+}
+
+// queryType returns the request query type b was built from
+func (b *BulkInsert) queryType() string {
+	if b.Upsert {
+		return "upsert"
+	}
+	return "bulk_insert"
+}
+
+// maxBindParams is the bind variable limit of MySQL and PostgreSQL statements
+const maxBindParams = 65535
+
+// validate checks identifiers and row widths before anything is written
+func (b *BulkInsert) validate() error {
+	if err := checkIdent("table", b.Table); err != nil {
+		return err
+	}
+	if b.Schema != "" {
+		if err := checkIdent("schema", b.Schema); err != nil {
+			return err
+		}
+	}
+	if len(b.Columns) == 0 {
+		return fmt.Errorf("columns must not be empty")
+	}
+	for _, col := range b.Columns {
+		if err := checkIdent("column", col); err != nil {
+			return err
+		}
+	}
+	if len(b.Rows) == 0 {
+		return fmt.Errorf("rows must not be empty")
+	}
+	if b.ChunkRows <= 0 {
+		return fmt.Errorf("chunk_rows must be positive, got %d", b.ChunkRows)
+	}
+	for i, row := range b.Rows {
+		if len(row) != len(b.Columns) {
+			return fmt.Errorf("row %d has %d values, want %d", i, len(row), len(b.Columns))
+		}
+	}
+	if b.Upsert {
+		for _, col := range b.Key {
+			if !slices.Contains(b.Columns, col) {
+				return fmt.Errorf("key column %q is not in columns", col)
+			}
+		}
+	}
+	return nil
+}
+
+// checkIdent validates an identifier taken from a request
+func checkIdent(kind, name string) error {
+	if len(name) > 128 || !identPattern.MatchString(name) {
+		return fmt.Errorf("invalid %s name %q", kind, name)
+	}
+	return nil
+}
+
+// qualifiedTable returns the quoted, optionally schema-qualified table name
+func (d *dialect) qualifiedTable(schema, table string) string {
+	if schema == "" {
+		return d.quoteIdent(table)
+	}
+	return d.quoteIdent(schema) + "." + d.quoteIdent(table)
+}
+
+// insertStatement builds an INSERT for rows rows of b's columns
+func (d *dialect) insertStatement(b *BulkInsert, rows int) string {
+	cols := d.quoteColumns(b.Columns)
+
+	values := make([]string, rows)
+	n := 0
+	for r := range values {
+		vars := make([]string, len(cols))
+		for c := range vars {
+			n++
+			vars[c] = d.bindVar(n)
+		}
+		values[r] = "(" + strings.Join(vars, ", ") + ")"
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		d.qualifiedTable(b.Schema, b.Table), strings.Join(cols, ", "), strings.Join(values, ", "))
+}
+
+// writeStatement builds the statement writing rows rows of b. arrayBind
+// dialects get a single-row statement executed once per row.
+func (d *dialect) writeStatement(b *BulkInsert, rows int) (string, error) {
+	if d.arrayBind {
+		rows = 1
+	}
+	if !b.Upsert {
+		return d.insertStatement(b, rows), nil
+	}
+	if d.upsert == nil {
+		return "", fmt.Errorf("upserts are not supported for this datasource")
+	}
+	return d.upsert(d, b, rows)
+}
+
+// execBulk inserts b chunk by chunk. With arrayBind dialects a single-row
+// INSERT is executed once per chunk with the chunk's rows as an extended
+// argument list (go-hdb bulk insert); otherwise each chunk is one multi-row
+// INSERT. Upserts work the same way with the dialect's upsert statement.
+// Failed chunks are reported in the result; with b.Atomic the first failure
+// rolls back everything. Like execDML, it only returns an error when ctx is
+// done, unless chunks were already committed: then the result reports them.
+func execBulk(ctx context.Context, db *sql.DB, d *dialect, b *BulkInsert, startTime time.Time) (*models.QueryResult, error) {
+	logger := logging.FromContext(ctx)
+
+	result := &models.QueryResult{QueryType: b.queryType()}
+	finish := func() *models.QueryResult {
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+		return result
+	}
+
+	if err := b.validate(); err != nil {
+		result.Error = err.Error()
+		return finish(), nil
+	}
+	if _, err := d.writeStatement(b, 1); err != nil {
+		result.Error = err.Error()
+		return finish(), nil
+	}
+
+	chunkRows := b.ChunkRows
+	if !d.arrayBind && chunkRows*len(b.Columns) > maxBindParams {
+		chunkRows = maxBindParams / len(b.Columns)
+	}
+
+	// Atomic inserts share one transaction across all chunks
+	var tx *sql.Tx
+	if b.Atomic {
+		var err error
+		if tx, err = db.BeginTx(ctx, nil); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Error = fmt.Sprintf("Failed to begin transaction: %v", err)
+			return finish(), nil
+		}
+	}
+
+	logger.Debug("Executing bulk write", "query_type", b.queryType(), "rows", len(b.Rows),
+		"chunk_rows", chunkRows, "atomic", b.Atomic)
+
+	var inserted int64
+	chunks := (len(b.Rows) + chunkRows - 1) / chunkRows
+
+	for index, first := 0, 0; first < len(b.Rows); index, first = index+1, first+chunkRows {
+		rows := b.Rows[first:min(first+chunkRows, len(b.Rows))]
+
+		affected, chunkInserted, err := execChunk(ctx, db, tx, d, b, rows)
+		if err != nil {
+			if ctx.Err() != nil {
+				if tx != nil {
+					tx.Rollback()
+					return nil, ctx.Err()
+				}
+				if result.CommittedChunks == 0 {
+					return nil, ctx.Err()
+				}
+				// Committed chunks stay written; tell Core which
+				logger.Warn("Bulk write interrupted", "committed_chunks", result.CommittedChunks,
+					"affected_rows", result.AffectedRows, "error", ctx.Err())
+				result.Error = fmt.Sprintf("Interrupted at chunk %d (row %d) after %d of %d chunks were committed: %v",
+					index, first, result.CommittedChunks, chunks, ctx.Err())
+				return finish(), nil
+			}
+			logger.Error("Bulk chunk failed", "chunk", index, "first_row", first, "error", err)
+			result.FailedChunks = append(result.FailedChunks, models.ChunkFailure{
+				Index:    index,
+				FirstRow: first,
+				Rows:     len(rows),
+				Error:    err.Error(),
+			})
+
+			if tx != nil {
+				if rollbackErr := tx.Rollback(); rollbackErr != nil {
+					logger.Error("Rollback failed", "error", rollbackErr)
+				}
+				result.AffectedRows = 0
+				result.Error = fmt.Sprintf("Chunk %d (rows %d-%d) failed: %v (transaction rolled back)",
+					index, first, first+len(rows)-1, err)
+				return finish(), nil
+			}
+			continue
+		}
+		result.AffectedRows += affected
+		inserted += chunkInserted
+		if tx == nil {
+			result.CommittedChunks++
+		}
+	}
+
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.AffectedRows = 0
+			result.Error = fmt.Sprintf("Failed to commit transaction: %v", err)
+			return finish(), nil
+		}
+		result.CommittedChunks = chunks
+	}
+
+	if b.Upsert && d.upsertCounts {
+		updated := result.AffectedRows - inserted
+		result.InsertedRows, result.UpdatedRows = &inserted, &updated
+	}
+
+	if n := len(result.FailedChunks); n > 0 {
+		result.Error = fmt.Sprintf("%d of %d chunks failed", n, chunks)
+		return finish(), nil
+	}
+
+	result.Success = true
+	return finish(), nil
+}
+
+// execChunk writes one chunk, in tx if given or else in its own transaction.
+// It returns the affected rows and, for upserts with upsertCounts, how many of
+// them were inserted.
+func execChunk(ctx context.Context, db *sql.DB, tx *sql.Tx, d *dialect, b *BulkInsert, rows [][]any) (affected, inserted int64, err error) {
+	own := tx == nil
+	if own {
+		if tx, err = db.BeginTx(ctx, nil); err != nil {
+			return 0, 0, err
+		}
+		defer tx.Rollback()
+	}
+
+	args := make([]any, 0, len(rows)*len(rows[0]))
+	for _, row := range rows {
+		args = append(args, bindParams(row)...)
+	}
+
+	query, err := d.writeStatement(b, len(rows))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if b.Upsert && d.upsertCounts {
+		affected, inserted, err = queryUpsertCounts(ctx, tx, query, args)
+	} else {
+		var res sql.Result
+		if res, err = tx.ExecContext(ctx, query, args...); err == nil {
+			if affected, err = res.RowsAffected(); err != nil {
+				affected, err = int64(len(rows)), nil
+			}
+		}
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if own {
+		if err := tx.Commit(); err != nil {
+			return 0, 0, err
+		}
+	}
+	return affected, inserted, nil
+}
+
+// queryUpsertCounts runs an upsert returning one "inserted" boolean per row
+func queryUpsertCounts(ctx context.Context, tx *sql.Tx, query string, args []any) (affected, inserted int64, err error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var wasInserted bool
+		if err := rows.Scan(&wasInserted); err != nil {
+			return 0, 0, err
+		}
+		affected++
+		if wasInserted {
+			inserted++
+		}
+	}
+	return affected, inserted, rows.Err()
+}
@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"nexus-query-agent/internal/models"
+)
+
+// CountMode selects how the total row count of a SELECT is obtained
+type CountMode string
+
+const (
+	CountExact     CountMode = "exact"      // COUNT(*) on every page (default)
+	CountNone      CountMode = "none"       // Never count
+	CountFirstPage CountMode = "first_page" // Count on the first page only
+	CountCached    CountMode = "cached"     // Reuse a recent count of the same query
+)
+
+// ParseCountMode validates a request's count mode; empty means CountExact
+func ParseCountMode(s string) (CountMode, error) {
+	switch m := CountMode(s); m {
+	case "":
+		return CountExact, nil
+	case CountExact, CountNone, CountFirstPage, CountCached:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid count_mode %q (want exact, none, first_page or cached)", s)
+	}
+}
+
+// maxCachedCounts bounds the count cache
+const maxCachedCounts = 10000
+
+// counts caches total row counts for CountCached across requests
+var counts = &countCache{entries: make(map[string]cachedCount)}
+
+type cachedCount struct {
+	total   int
+	expires time.Time
+}
+
+// countCache maps query hashes to recent row counts
+type countCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedCount
+}
+
+// countKey hashes the datasource identity and credentials, query and params,
+// so users with different privileges never share counts
+func countKey(ds *models.DatasourceInfo, query string, args []any) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%s\x00%s\x00", ds.Type, ds.ID, credentialFingerprint(ds), query)
+	json.NewEncoder(h).Encode(args)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *countCache) get(key string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return 0, false
+	}
+	return e.total, true
+}
+
+func (c *countCache) put(key string, total int, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxCachedCounts {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// Still full: drop an arbitrary entry
+		for k := range c.entries {
+			if len(c.entries) < maxCachedCounts {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedCount{total: total, expires: now.Add(ttl)}
+}
+
+// pageWriter passes on the first limit rows and notes whether more followed.
+// Pages are fetched with one extra row so has_more needs no COUNT.
+type pageWriter struct {
+	RowWriter
+	limit   int
+	written int
+	more    bool
+}
+
+func (w *pageWriter) WriteRow(row map[string]any) error {
+	if w.written == w.limit {
+		w.more = true
+		return nil
+	}
+	w.written++
+	return w.RowWriter.WriteRow(row)
+}
@@ -1,28 +1,89 @@
 package executor
 
 import (
+	"context"
+	"time"
+
 	"nexus-query-agent/internal/config"
 	"nexus-query-agent/internal/models"
 )
 
 // Executor interface for database query execution
 type Executor interface {
-	// Execute runs a query with datasource info and returns paginated results
-	Execute(ds *models.DatasourceInfo, query string, page, limit int) (*models.QueryResult, error)
-}
-
-// NewExecutor creates appropriate executor based on datasource type
-func NewExecutor(dsType string, limits *config.LimitsConfig) (Executor, error) {
-	switch dsType {
-	case "sap":
-		return NewSapExecutor(limits), nil
-	// case "mysql":
-	// 	return NewMySQLExecutor(limits), nil
-	// case "postgres":
-	// 	return NewPostgresExecutor(limits), nil
-	default:
+	// Execute runs a query with datasource info and returns paginated results.
+	// It returns ctx.Err() when the query is cancelled or times out.
+	Execute(ctx context.Context, ds *models.DatasourceInfo, query string, params []any, paging Paging) (*models.QueryResult, error)
+}
+
+// Paging selects the page of a SELECT result to return
+type Paging struct {
+	Page  int // 1-based; ignored for keyset pagination
+	Limit int
+
+	// Keyset switches from LIMIT/OFFSET to keyset (cursor) pagination
+	Keyset *Keyset
+
+	// Count selects how the total row count is obtained; empty counts every page
+	Count    CountMode
+	CountTTL time.Duration // Lifetime of counts cached by CountCached
+
+	// ReadOnly runs the page and count queries in a read-only transaction
+	ReadOnly bool
+}
+
+// StreamExecutor is implemented by executors that can stream SELECT results
+type StreamExecutor interface {
+	// ExecuteStream runs a query and hands columns and rows to w as they are read
+	ExecuteStream(ctx context.Context, ds *models.DatasourceInfo, query string, params []any, paging Paging, w RowWriter) (*models.QueryResult, error)
+}
+
+// DMLExecutor is implemented by executors that run INSERT, UPDATE and DELETE
+type DMLExecutor interface {
+	// ExecuteDML runs a single statement in a transaction and reports affected rows
+	ExecuteDML(ctx context.Context, ds *models.DatasourceInfo, queryType, query string, params []any) (*models.QueryResult, error)
+}
+
+// TransactionExecutor is implemented by executors that run statement batches
+type TransactionExecutor interface {
+	// ExecuteTransaction runs statements in order in one transaction, all-or-nothing
+	ExecuteTransaction(ctx context.Context, ds *models.DatasourceInfo, statements []models.Statement) (*models.QueryResult, error)
+}
+
+// BulkExecutor is implemented by executors that insert or upsert row
+// matrices in chunks
+type BulkExecutor interface {
+	ExecuteBulk(ctx context.Context, ds *models.DatasourceInfo, b *BulkInsert) (*models.QueryResult, error)
+}
+
+// ProcedureExecutor is implemented by executors that call stored procedures
+type ProcedureExecutor interface {
+	ExecuteCall(ctx context.Context, ds *models.DatasourceInfo, call *ProcedureCall) (*models.QueryResult, error)
+}
+
+// ProcedureCall is a stored procedure and its IN/INOUT argument values
+type ProcedureCall struct {
+	Schema    string // Optional; defaults to the session's schema
+	Procedure string
+	Args      map[string]any // By parameter name
+}
+
+// MetadataExecutor is implemented by executors that can browse the catalog.
+// Listings are paginated like SELECT results.
+type MetadataExecutor interface {
+	ListSchemas(ctx context.Context, ds *models.DatasourceInfo, page, limit int) (*models.QueryResult, error)
+	ListTables(ctx context.Context, ds *models.DatasourceInfo, schema string, page, limit int) (*models.QueryResult, error)
+	ListViews(ctx context.Context, ds *models.DatasourceInfo, schema string, page, limit int) (*models.QueryResult, error)
+	// DescribeTable returns the columns of a table or view in Columns
+	DescribeTable(ctx context.Context, ds *models.DatasourceInfo, schema, table string) (*models.QueryResult, error)
+}
+
+// NewExecutor creates the executor registered for the datasource type
+func NewExecutor(dsType string, limits *config.LimitsConfig, pools *PoolManager) (Executor, error) {
+	d, ok := Lookup(dsType)
+	if !ok {
 		return nil, &UnsupportedDatasourceError{Type: dsType}
 	}
+	return d.Factory(limits, pools), nil
 }
 
 // UnsupportedDatasourceError is returned when datasource type is not supported
@@ -0,0 +1,216 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The SQL guard checks a request's query before it reaches the database: it
+// must be a single statement of the declared query type. It only tokenizes
+// enough SQL to skip literals, quoted identifiers and comments; it is not a
+// parser, and where it can't tell it rejects.
+
+// sqlSyntax selects the lexical rules of the guard's tokenizer
+type sqlSyntax int
+
+const (
+	// syntaxStandard has '' strings, "" identifiers, -- and /* */ comments
+	syntaxStandard sqlSyntax = iota
+	// syntaxMySQL adds `` identifiers, # comments and executable /*! */
+	// comments; -- only starts a comment when followed by whitespace.
+	// Whether a backslash escapes a quote depends on the server's sql_mode,
+	// so literals containing one are rejected.
+	syntaxMySQL
+	// syntaxPostgres adds E'' strings with backslash escapes and $tag$ quoting
+	syntaxPostgres
+)
+
+var errMultipleStatements = errors.New("multiple statements are not allowed")
+
+// ddlKeywords start statements that change the schema or privileges
+var ddlKeywords = map[string]bool{
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true,
+	"RENAME": true, "GRANT": true, "REVOKE": true, "COMMENT": true,
+}
+
+// selectForbidden are keywords a SELECT must not contain anywhere: writes
+// (including data-modifying CTEs), DDL, procedure calls, SELECT ... INTO and
+// FOR UPDATE row locks
+var selectForbidden = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true, "GRANT": true, "REVOKE": true,
+	"CALL": true, "EXEC": true, "EXECUTE": true, "INTO": true,
+}
+
+// writeKeywords start the statements a transaction request may contain
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true,
+}
+
+// guardQuery checks that query is a single statement of queryType: "select",
+// "insert", "update", "delete", or "transaction" for any single write
+func guardQuery(d *dialect, queryType, query string) error {
+	statements, err := sqlStatements(d.syntax, query)
+	if err != nil {
+		return err
+	}
+	switch len(statements) {
+	case 0:
+		return errors.New("query is empty")
+	case 1:
+	default:
+		return errMultipleStatements
+	}
+
+	words := statements[0]
+	verb := words[0]
+	if ddlKeywords[verb] {
+		return fmt.Errorf("DDL statements are not allowed")
+	}
+
+	switch queryType {
+	case "select":
+		if verb != "SELECT" && verb != "WITH" {
+			return fmt.Errorf("select query must start with SELECT or WITH, not %s", verb)
+		}
+		for _, w := range words {
+			if selectForbidden[w] {
+				return fmt.Errorf("select query must not contain %s", w)
+			}
+		}
+	case "transaction":
+		if !writeKeywords[verb] {
+			return fmt.Errorf("transaction statements must be INSERT, UPDATE, DELETE, MERGE or UPSERT, not %s", verb)
+		}
+	default:
+		if want := strings.ToUpper(queryType); verb != want {
+			return fmt.Errorf("%s query must start with %s, not %s", queryType, want, verb)
+		}
+	}
+	return nil
+}
+
+// sqlStatements splits query at semicolons into statements, each given as its
+// upper-cased words. Literals, quoted identifiers, comments and punctuation
+// are dropped; empty statements are skipped.
+func sqlStatements(syntax sqlSyntax, query string) ([][]string, error) {
+	var (
+		statements [][]string
+		words      []string
+	)
+	isWord := func(c byte) bool {
+		return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' ||
+			c >= 0x80 || c == '#' && syntax != syntaxMySQL
+	}
+
+	for i := 0; i < len(query); {
+		c, rest := query[i], query[i:]
+		switch {
+		case c == ';':
+			if len(words) > 0 {
+				statements = append(statements, words)
+				words = nil
+			}
+			i++
+		case c == '\'' || c == '"' || c == '`' && syntax == syntaxMySQL:
+			// E'...' is the word "E" followed by the literal
+			escapes := syntax == syntaxPostgres && c == '\'' && len(words) > 0 &&
+				words[len(words)-1] == "E" && i > 0 && (query[i-1] == 'E' || query[i-1] == 'e')
+			n, err := quotedLength(rest, escapes, syntax == syntaxMySQL && c != '`')
+			if err != nil {
+				return nil, err
+			}
+			i += n
+		case lineComment(syntax, rest):
+			if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+				i += nl + 1
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(rest, "/*!") && syntax == syntaxMySQL:
+			// MySQL executes the content of /*! ... */; check it like any SQL
+			i += 3
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated comment")
+			}
+			i += end + 4
+		case c == '$' && syntax == syntaxPostgres && dollarTag(rest) != "":
+			tag := dollarTag(rest)
+			end := strings.Index(rest[len(tag):], tag)
+			if end < 0 {
+				return nil, errors.New("unterminated dollar-quoted string")
+			}
+			i += end + 2*len(tag)
+		case isWord(c):
+			j := i + 1
+			for j < len(query) && isWord(query[j]) {
+				j++
+			}
+			words = append(words, strings.ToUpper(query[i:j]))
+			i = j
+		default:
+			i++
+		}
+	}
+	if len(words) > 0 {
+		statements = append(statements, words)
+	}
+	return statements, nil
+}
+
+// lineComment reports whether s starts with a comment that runs to the end of
+// the line. MySQL reads -- without following whitespace or control character
+// as two minus signs, so 1--1 is an expression, not a comment.
+func lineComment(syntax sqlSyntax, s string) bool {
+	if syntax != syntaxMySQL {
+		return strings.HasPrefix(s, "--")
+	}
+	if s[0] == '#' {
+		return true
+	}
+	return strings.HasPrefix(s, "--") && (len(s) == 2 || s[2] <= ' ' || s[2] == 0x7f)
+}
+
+// quotedLength returns the length of the literal or quoted identifier s starts
+// with. A doubled quote character stands for itself; with escapes a backslash
+// escapes the next character, and with noBackslash a backslash is rejected.
+func quotedLength(s string, escapes, noBackslash bool) (int, error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if noBackslash {
+				return 0, errors.New("backslashes in literals are ambiguous; use bind parameters")
+			}
+			if escapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	return 0, errors.New("unterminated quoted string")
+}
+
+// dollarTag returns the $tag$ or $$ that s starts with, or ""
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= 0x80,
+			c >= '0' && c <= '9' && i > 1:
+		default:
+			return ""
+		}
+	}
+	return ""
+}
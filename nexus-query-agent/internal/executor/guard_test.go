@@ -0,0 +1,76 @@
+package executor
+
+import "testing"
+
+func TestGuardQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		syntax    sqlSyntax
+		queryType string
+		query     string
+		ok        bool
+	}{
+		// Statement types
+		{"select", syntaxStandard, "select", "SELECT * FROM t WHERE id = ?", true},
+		{"with", syntaxStandard, "select", "WITH x AS (SELECT 1) SELECT * FROM x", true},
+		{"empty", syntaxStandard, "select", " ; -- nothing", false},
+		{"wrong type", syntaxStandard, "select", "DELETE FROM t", false},
+		{"ddl", syntaxStandard, "delete", "DROP TABLE t", false},
+		{"select into", syntaxStandard, "select", "SELECT * INTO t2 FROM t", false},
+		{"writable cte", syntaxPostgres, "select", "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", false},
+		{"insert", syntaxStandard, "insert", "INSERT INTO t (a) VALUES (?)", true},
+		{"insert as update", syntaxStandard, "update", "INSERT INTO t (a) VALUES (1)", false},
+		{"transaction write", syntaxStandard, "transaction", "UPSERT t VALUES (1) WITH PRIMARY KEY", true},
+		{"transaction select", syntaxStandard, "transaction", "SELECT 1", false},
+
+		// Multiple statements
+		{"trailing semicolon", syntaxStandard, "select", "SELECT 1;", true},
+		{"two statements", syntaxStandard, "select", "SELECT 1; DELETE FROM t", false},
+		{"two statements mysql", syntaxMySQL, "select", "SELECT 1;DELETE FROM t", false},
+		{"two statements postgres", syntaxPostgres, "select", "SELECT 1; DELETE FROM t", false},
+
+		// Quoting
+		{"semicolon in literal", syntaxStandard, "select", "SELECT ';DELETE FROM t' FROM dummy", true},
+		{"doubled quote", syntaxStandard, "select", "SELECT 'it''s; DELETE FROM t' FROM dummy", true},
+		{"doubled quote escape", syntaxStandard, "select", "SELECT 'a''; DELETE FROM t", false},
+		{"semicolon in identifier", syntaxStandard, "select", `SELECT "a;DELETE" FROM t`, true},
+		{"keyword in identifier", syntaxStandard, "select", `SELECT "delete" FROM t`, true},
+		{"unterminated literal", syntaxStandard, "select", "SELECT 'abc", false},
+		{"standard backslash", syntaxStandard, "select", `SELECT 'a\'; DELETE FROM t`, false},
+		{"mysql backtick", syntaxMySQL, "select", "SELECT `a;DELETE` FROM t", true},
+		{"mysql backslash", syntaxMySQL, "select", `SELECT 'a\'; DELETE FROM t; -- '`, false},
+		{"mysql double quotes", syntaxMySQL, "select", `SELECT "a;b" FROM t`, true},
+		{"postgres escape string", syntaxPostgres, "select", `SELECT E'a\'; DELETE FROM t; --'`, true},
+		{"postgres plain backslash", syntaxPostgres, "select", `SELECT 'a\'; DELETE FROM t`, false},
+		{"postgres dollar quote", syntaxPostgres, "select", "SELECT $tag$;DELETE FROM t$tag$", true},
+		{"postgres unterminated dollar", syntaxPostgres, "select", "SELECT $$;DELETE FROM t", false},
+		{"postgres positional param", syntaxPostgres, "select", "SELECT * FROM t WHERE a = $1", true},
+
+		// Comments
+		{"line comment", syntaxStandard, "select", "SELECT 1 -- ; DELETE FROM t", true},
+		{"block comment", syntaxStandard, "select", "SELECT 1 /* ; DELETE FROM t */", true},
+		{"unterminated block comment", syntaxStandard, "select", "SELECT 1 /* ; DELETE FROM t", false},
+		{"comment hides nothing", syntaxStandard, "select", "SELECT 1 -- x\n; DELETE FROM t", false},
+		{"standard double dash", syntaxStandard, "select", "SELECT 1--1; DELETE FROM t", true},
+		{"mysql double dash expression", syntaxMySQL, "select", "SELECT 1--1; DELETE FROM t", false},
+		{"mysql double dash expression no space", syntaxMySQL, "select", "SELECT 1--1;DELETE FROM t", false},
+		{"mysql double dash comment", syntaxMySQL, "select", "SELECT 1 -- ; DELETE FROM t", true},
+		{"mysql double dash tab", syntaxMySQL, "select", "SELECT 1 --\t; DELETE FROM t", true},
+		{"mysql double dash end", syntaxMySQL, "select", "SELECT 1 --", true},
+		{"mysql hash comment", syntaxMySQL, "select", "SELECT 1 # ; DELETE FROM t", true},
+		{"mysql executable comment", syntaxMySQL, "select", "SELECT 1 /*! ; DELETE FROM t */", false},
+		{"postgres hash operator", syntaxPostgres, "select", "SELECT 5 # 3", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guardQuery(&dialect{syntax: tt.syntax}, tt.queryType, tt.query)
+			if tt.ok && err != nil {
+				t.Errorf("guardQuery(%q) = %v, want nil", tt.query, err)
+			}
+			if !tt.ok && err == nil {
+				t.Errorf("guardQuery(%q) = nil, want error", tt.query)
+			}
+		})
+	}
+}
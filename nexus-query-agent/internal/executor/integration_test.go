@@ -0,0 +1,59 @@
+//go:build integration
+
+package executor
+
+// Integration tests run against the databases of docker-compose.test.yml:
+//
+//	docker-compose -f docker-compose.test.yml up -d
+//	go test -tags integration ./internal/executor/
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"nexus-query-agent/internal/config"
+)
+
+func testLimits() *config.LimitsConfig {
+	return &config.LimitsConfig{
+		MaxRows:         1000,
+		MaxStreamRows:   1000,
+		MaxOpenConns:    2,
+		MaxIdleConns:    2,
+		ConnMaxIdleTime: time.Minute,
+		PoolIdleTimeout: time.Minute,
+		MaxBulkRows:     1000,
+		BulkChunkRows:   100,
+	}
+}
+
+func newTestPools(t *testing.T, limits *config.LimitsConfig) *PoolManager {
+	t.Helper()
+	pools := NewPoolManager(limits, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(pools.Close)
+	return pools
+}
+
+// setupDB runs statements directly on the database, bypassing the query
+// guard, and returns the handle for further checks
+func setupDB(t *testing.T, driverName, dsn string, statements ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Ping(); err != nil {
+		t.Fatalf("%s test database is not reachable (docker-compose -f docker-compose.test.yml up -d): %v", driverName, err)
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
@@ -0,0 +1,210 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"nexus-query-agent/internal/models"
+)
+
+// Keyset paginates by a unique ordering key instead of OFFSET, so deep pages
+// cost the same as the first and concurrent writes don't shift page borders
+type Keyset struct {
+	Columns []string // Result column names forming a unique, non-null key
+	Desc    bool     // Order descending instead of ascending
+	Cursor  string   // next_cursor of the previous page; empty for the first page
+}
+
+// identPattern restricts keyset columns to plain identifiers so they can be
+// quoted safely
+var identPattern = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_$#]*$`)
+
+// maxKeysetColumns bounds the size of the expanded key comparison
+const maxKeysetColumns = 8
+
+// keysetQuery wraps query so it returns the limit rows following the cursor in
+// key order. The returned args are args plus the cursor values.
+func keysetQuery(d *dialect, query string, args []any, k *Keyset, limit int) (string, []any, error) {
+	if len(k.Columns) == 0 || len(k.Columns) > maxKeysetColumns {
+		return "", nil, fmt.Errorf("order_key must name 1 to %d columns", maxKeysetColumns)
+	}
+	quoted := make([]string, len(k.Columns))
+	for i, col := range k.Columns {
+		if err := checkIdent("keyset column", col); err != nil {
+			return "", nil, err
+		}
+		quoted[i] = d.quoteIdent(col)
+	}
+
+	op, dir := ">", "ASC"
+	if k.Desc {
+		op, dir = "<", "DESC"
+	}
+
+	var where string
+	keyArgs := slices.Clone(args)
+	if k.Cursor != "" {
+		values, err := decodeCursor(k)
+		if err != nil {
+			return "", nil, err
+		}
+
+		// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... - unlike row value
+		// comparison this works on every dialect and can use the key's index
+		terms := make([]string, len(quoted))
+		for i := range quoted {
+			conds := make([]string, 0, i+1)
+			for j := 0; j < i; j++ {
+				keyArgs = append(keyArgs, values[j])
+				conds = append(conds, quoted[j]+" = "+d.bindVar(len(keyArgs)))
+			}
+			keyArgs = append(keyArgs, values[i])
+			conds = append(conds, quoted[i]+" "+op+" "+d.bindVar(len(keyArgs)))
+			terms[i] = "(" + strings.Join(conds, " AND ") + ")"
+		}
+		where = "WHERE " + strings.Join(terms, " OR ")
+	}
+
+	order := make([]string, len(quoted))
+	for i, q := range quoted {
+		order[i] = q + " " + dir
+	}
+
+	return fmt.Sprintf(`
+		SELECT * FROM (%s) AS subquery
+		%s
+		ORDER BY %s
+		LIMIT %d
+	`, query, where, strings.Join(order, ", "), limit), keyArgs, nil
+}
+
+// keysetWriter checks that the key columns are part of the result and
+// remembers the last row for the next cursor
+type keysetWriter struct {
+	RowWriter
+	keyset *Keyset
+	last   map[string]any
+}
+
+func (w *keysetWriter) WriteColumns(columns []models.ColumnInfo) error {
+	for _, col := range w.keyset.Columns {
+		if !slices.ContainsFunc(columns, func(c models.ColumnInfo) bool { return c.Name == col }) {
+			return &scanError{fmt.Sprintf("order_key column %q is not part of the result", col)}
+		}
+	}
+	return w.RowWriter.WriteColumns(columns)
+}
+
+func (w *keysetWriter) WriteRow(row map[string]any) error {
+	w.last = row
+	return w.RowWriter.WriteRow(row)
+}
+
+// cursor is the decoded form of next_cursor
+type cursor struct {
+	Columns []string          `json:"k"`
+	Desc    bool              `json:"d,omitempty"`
+	Values  []json.RawMessage `json:"v"`
+}
+
+// cursorTime tags timestamps so they are bound as time.Time again
+type cursorTime struct {
+	Time time.Time `json:"$t"`
+}
+
+// cursorNumber tags exact numerics (e.g. NUMERIC as json.Number) so they are
+// bound as strings rather than lossy floats
+type cursorNumber struct {
+	Number string `json:"$n"`
+}
+
+// nextCursor encodes the key of the last row written
+func (w *keysetWriter) nextCursor() (string, error) {
+	if w.last == nil {
+		return "", nil
+	}
+
+	c := cursor{Columns: w.keyset.Columns, Desc: w.keyset.Desc}
+	for _, col := range w.keyset.Columns {
+		var v any
+		switch val := w.last[col].(type) {
+		case nil:
+			return "", fmt.Errorf("order_key column %q is NULL; keyset columns must be non-null", col)
+		case time.Time:
+			v = cursorTime{Time: val}
+		case json.Number:
+			v = cursorNumber{Number: val.String()}
+		default:
+			v = val
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("order_key column %q cannot be used in a cursor: %v", col, err)
+		}
+		c.Values = append(c.Values, raw)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// decodeCursor returns the bind values of a cursor, checking that it was
+// issued for the same order key
+func decodeCursor(k *Keyset) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(k.Cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || len(c.Values) != len(c.Columns) {
+		return nil, errInvalidCursor
+	}
+	if !slices.Equal(c.Columns, k.Columns) || c.Desc != k.Desc {
+		return nil, errors.New("cursor does not match order_key")
+	}
+
+	values := make([]any, len(c.Values))
+	for i, raw := range c.Values {
+		var t cursorTime
+		var n cursorNumber
+		switch {
+		case bytes.HasPrefix(raw, []byte(`{"$t"`)) && json.Unmarshal(raw, &t) == nil:
+			values[i] = t.Time
+		case bytes.HasPrefix(raw, []byte(`{"$n"`)) && json.Unmarshal(raw, &n) == nil:
+			values[i] = n.Number
+		default:
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return nil, errInvalidCursor
+			}
+			// Only scalars are ever encoded; anything else was tampered with
+			switch v.(type) {
+			case map[string]any, []any:
+				return nil, errInvalidCursor
+			}
+			if num, ok := v.(json.Number); ok {
+				if whole, err := num.Int64(); err == nil {
+					v = whole
+				} else if f, err := num.Float64(); err == nil {
+					v = f
+				}
+			}
+			values[i] = v
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,224 @@
+package executor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus-query-agent/internal/models"
+)
+
+// encodeCursor builds a cursor as nextCursor would, or tampered with
+func encodeCursor(t *testing.T, c any) string {
+	t.Helper()
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestKeysetQuery(t *testing.T) {
+	postgres := &dialect{placeholder: func(n int) string { return "$" + strconv.Itoa(n) }}
+	mysql := &dialect{quote: mysqlQuote}
+	cursorAB := encodeCursor(t, cursor{Columns: []string{"a", "b"}, Values: []json.RawMessage{[]byte("1"), []byte(`"x"`)}})
+	cursorABDesc := encodeCursor(t, cursor{Columns: []string{"a", "b"}, Desc: true, Values: []json.RawMessage{[]byte("1"), []byte(`"x"`)}})
+	cursorA := encodeCursor(t, cursor{Columns: []string{"a"}, Values: []json.RawMessage{[]byte("7")}})
+
+	tests := []struct {
+		name      string
+		d         *dialect
+		args      []any
+		keyset    Keyset
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name:      "first page",
+			d:         &dialect{},
+			keyset:    Keyset{Columns: []string{"a", "b"}},
+			wantQuery: `SELECT * FROM (SELECT a, b FROM t) AS subquery ORDER BY "a" ASC, "b" ASC LIMIT 11`,
+			wantArgs:  []any{},
+		},
+		{
+			name:      "one column",
+			d:         &dialect{},
+			keyset:    Keyset{Columns: []string{"a"}, Cursor: cursorA},
+			wantQuery: `SELECT * FROM (SELECT a, b FROM t) AS subquery WHERE ("a" > ?) ORDER BY "a" ASC LIMIT 11`,
+			wantArgs:  []any{int64(7)},
+		},
+		{
+			name:      "two columns",
+			d:         &dialect{},
+			keyset:    Keyset{Columns: []string{"a", "b"}, Cursor: cursorAB},
+			wantQuery: `SELECT * FROM (SELECT a, b FROM t) AS subquery WHERE ("a" > ?) OR ("a" = ? AND "b" > ?) ORDER BY "a" ASC, "b" ASC LIMIT 11`,
+			wantArgs:  []any{int64(1), int64(1), "x"},
+		},
+		{
+			name:      "descending",
+			d:         &dialect{},
+			keyset:    Keyset{Columns: []string{"a", "b"}, Desc: true, Cursor: cursorABDesc},
+			wantQuery: `SELECT * FROM (SELECT a, b FROM t) AS subquery WHERE ("a" < ?) OR ("a" = ? AND "b" < ?) ORDER BY "a" DESC, "b" DESC LIMIT 11`,
+			wantArgs:  []any{int64(1), int64(1), "x"},
+		},
+		{
+			name:      "numbered placeholders after query args",
+			d:         postgres,
+			args:      []any{"p1"},
+			keyset:    Keyset{Columns: []string{"a", "b"}, Cursor: cursorAB},
+			wantQuery: `SELECT * FROM (SELECT a, b FROM t) AS subquery WHERE ("a" > $2) OR ("a" = $3 AND "b" > $4) ORDER BY "a" ASC, "b" ASC LIMIT 11`,
+			wantArgs:  []any{"p1", int64(1), int64(1), "x"},
+		},
+		{
+			name:      "mysql quoting",
+			d:         mysql,
+			keyset:    Keyset{Columns: []string{"a"}, Cursor: cursorA},
+			wantQuery: "SELECT * FROM (SELECT a, b FROM t) AS subquery WHERE (`a` > ?) ORDER BY `a` ASC LIMIT 11",
+			wantArgs:  []any{int64(7)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := keysetQuery(tt.d, "SELECT a, b FROM t", tt.args, &tt.keyset, 11)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(strings.Fields(query), " "); got != tt.wantQuery {
+				t.Errorf("query =\n%s\nwant\n%s", got, tt.wantQuery)
+			}
+			if len(args) == 0 && len(tt.wantArgs) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestKeysetQueryInvalidColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+	}{
+		{"none", nil},
+		{"too many", strings.Split("a,b,c,d,e,f,g,h,i", ",")},
+		{"empty name", []string{""}},
+		{"quote", []string{`a" FROM t; --`}},
+		{"semicolon", []string{"a;b"}},
+		{"space", []string{"a b"}},
+		{"leading digit", []string{"1a"}},
+		{"too long", []string{strings.Repeat("a", 129)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &Keyset{Columns: tt.columns}
+			if query, _, err := keysetQuery(&dialect{}, "SELECT 1", nil, k, 10); err == nil {
+				t.Errorf("keysetQuery(%q) = %q, want error", tt.columns, query)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 2, 29, 13, 45, 0, 123456789, time.UTC)
+	k := &Keyset{Columns: []string{"i", "f", "s", "n", "t", "b"}}
+	w := &keysetWriter{RowWriter: &rowCollector{}, keyset: k}
+	row := map[string]any{
+		"i": int64(9007199254740993),
+		"f": 1.5,
+		"s": "x'y",
+		"n": json.Number("12345678901234567890.01"),
+		"t": ts,
+		"b": true,
+	}
+	if err := w.WriteRow(row); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	if k.Cursor, err = w.nextCursor(); err != nil {
+		t.Fatal(err)
+	}
+	values, err := decodeCursor(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []any{int64(9007199254740993), 1.5, "x'y", "12345678901234567890.01", ts, true}
+	if len(values) != len(want) {
+		t.Fatalf("values = %#v, want %#v", values, want)
+	}
+	for i := range want {
+		if wt, ok := want[i].(time.Time); ok {
+			if got, ok := values[i].(time.Time); !ok || !got.Equal(wt) {
+				t.Errorf("value %d = %#v, want %v", i, values[i], wt)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(values[i], want[i]) {
+			t.Errorf("value %d = %#v, want %#v", i, values[i], want[i])
+		}
+	}
+}
+
+func TestNextCursorNullKey(t *testing.T) {
+	k := &Keyset{Columns: []string{"a"}}
+	w := &keysetWriter{RowWriter: &rowCollector{}, keyset: k}
+	if cursor, err := w.nextCursor(); err != nil || cursor != "" {
+		t.Errorf("nextCursor() without rows = %q, %v, want empty", cursor, err)
+	}
+	w.WriteRow(map[string]any{"a": nil})
+	if _, err := w.nextCursor(); err == nil {
+		t.Error("nextCursor() with NULL key = nil error")
+	}
+}
+
+func TestKeysetWriterMissingColumn(t *testing.T) {
+	w := &keysetWriter{RowWriter: &rowCollector{}, keyset: &Keyset{Columns: []string{"id"}}}
+	if err := w.WriteColumns([]models.ColumnInfo{{Name: "name"}}); err == nil {
+		t.Error("WriteColumns without the key column = nil error")
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	columns := []string{"a", "b"}
+	values := []json.RawMessage{[]byte("1"), []byte("2")}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"k":["a","b"],"v":[1,2]}`))},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("a,b"))},
+		{"truncated json", base64.RawURLEncoding.EncodeToString([]byte(`{"k":["a","b"],"v":[1,`))},
+		{"empty object", encodeCursor(t, map[string]any{})},
+		{"other columns", encodeCursor(t, cursor{Columns: []string{"a", "c"}, Values: values})},
+		{"reordered columns", encodeCursor(t, cursor{Columns: []string{"b", "a"}, Values: values})},
+		{"other direction", encodeCursor(t, cursor{Columns: columns, Desc: true, Values: values})},
+		{"missing value", encodeCursor(t, cursor{Columns: columns, Values: values[:1]})},
+		{"extra value", encodeCursor(t, cursor{Columns: columns, Values: append(values, []byte("3"))})},
+		{"object value", encodeCursor(t, cursor{Columns: columns, Values: []json.RawMessage{[]byte("1"), []byte(`{"x":1}`)}})},
+		{"array value", encodeCursor(t, cursor{Columns: columns, Values: []json.RawMessage{[]byte("[1]"), []byte("2")}})},
+		{"bad time", encodeCursor(t, cursor{Columns: columns, Values: []json.RawMessage{[]byte(`{"$t":"yesterday"}`), []byte("2")}})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &Keyset{Columns: columns, Cursor: tt.cursor}
+			if values, err := decodeCursor(k); err == nil {
+				t.Errorf("decodeCursor(%s) = %#v, want error", tt.cursor, values)
+			}
+			if query, _, err := keysetQuery(&dialect{}, "SELECT 1", nil, k, 10); err == nil {
+				t.Errorf("keysetQuery with cursor %s = %q, want error", tt.cursor, query)
+			}
+		})
+	}
+}
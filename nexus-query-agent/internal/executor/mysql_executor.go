@@ -0,0 +1,169 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"nexus-query-agent/internal/config"
+	"nexus-query-agent/internal/metrics"
+	"nexus-query-agent/internal/models"
+)
+
+// MySQLExecutor handles MySQL query execution with pooled connections
+type MySQLExecutor struct {
+	limits *config.LimitsConfig
+	pools  *PoolManager
+}
+
+func init() {
+	Register(Driver{
+		Type: "mysql",
+		Factory: func(limits *config.LimitsConfig, pools *PoolManager) Executor {
+			return NewMySQLExecutor(limits, pools)
+		},
+		Prototype:    (*MySQLExecutor)(nil),
+		Capabilities: []Capability{CapSelect, CapStreaming, CapDML, CapTransactions, CapBulk, CapUpsert},
+	})
+}
+
+// NewMySQLExecutor creates a new MySQL executor
+func NewMySQLExecutor(limits *config.LimitsConfig, pools *PoolManager) *MySQLExecutor {
+	return &MySQLExecutor{
+		limits: limits,
+		pools:  pools,
+	}
+}
+
+// mysqlDialect parses MySQL text protocol values back into typed values
+var mysqlDialect = &dialect{convert: mysqlValue, quote: mysqlQuote, upsert: mysqlUpsert, syntax: syntaxMySQL}
+
+// connect returns a pooled connection for the datasource and its release func
+func (e *MySQLExecutor) connect(ctx context.Context, ds *models.DatasourceInfo) (*sql.DB, func(), error) {
+	dbName := ds.Database
+	if dbName == "" {
+		dbName = ds.DatabaseName
+	}
+
+	cfg := mysql.NewConfig()
+	cfg.User = ds.Username
+	cfg.Passwd = ds.Password
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:%d", ds.Host, ds.Port)
+	cfg.DBName = dbName
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+
+	return e.pools.Get(ctx, ds, "mysql", cfg.FormatDSN())
+}
+
+// Execute runs a query using datasource info from the request
+func (e *MySQLExecutor) Execute(ctx context.Context, ds *models.DatasourceInfo, query string, params []any, paging Paging) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "select", startTime)
+
+	return withDB(ctx, e.connect, ds, "select", func(db *sql.DB) (*models.QueryResult, error) {
+		paging = normalizePage(paging, e.limits.MaxRows)
+		result, err := selectPage(ctx, db, mysqlDialect, ds, query, params, paging)
+		if err != nil {
+			return nil, err
+		}
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+
+		return result, nil
+	})
+}
+
+// ExecuteStream runs a SELECT and hands rows to w as they are read.
+// The returned result carries pagination and timing but no rows.
+func (e *MySQLExecutor) ExecuteStream(ctx context.Context, ds *models.DatasourceInfo, query string, params []any, paging Paging, w RowWriter) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "select", startTime)
+
+	return withDB(ctx, e.connect, ds, "select", func(db *sql.DB) (*models.QueryResult, error) {
+		paging = normalizePage(paging, e.limits.MaxStreamRows)
+		result, err := streamPage(ctx, db, mysqlDialect, ds, query, params, paging, w)
+		if err != nil {
+			return nil, err
+		}
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+
+		return result, nil
+	})
+}
+
+// ExecuteDML executes INSERT, UPDATE, DELETE with transaction handling
+func (e *MySQLExecutor) ExecuteDML(ctx context.Context, ds *models.DatasourceInfo, queryType, query string, params []any) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, queryType, startTime)
+
+	return withDB(ctx, e.connect, ds, queryType, func(db *sql.DB) (*models.QueryResult, error) {
+		return execDML(ctx, db, mysqlDialect, queryType, query, params, startTime)
+	})
+}
+
+// ExecuteTransaction runs statements in order in a single transaction
+func (e *MySQLExecutor) ExecuteTransaction(ctx context.Context, ds *models.DatasourceInfo, statements []models.Statement) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "transaction", startTime)
+
+	return withDB(ctx, e.connect, ds, "transaction", func(db *sql.DB) (*models.QueryResult, error) {
+		return execTransaction(ctx, db, mysqlDialect, statements, startTime)
+	})
+}
+
+// ExecuteBulk inserts or upserts a row matrix in chunks
+func (e *MySQLExecutor) ExecuteBulk(ctx context.Context, ds *models.DatasourceInfo, b *BulkInsert) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, b.queryType(), startTime)
+
+	return withDB(ctx, e.connect, ds, b.queryType(), func(db *sql.DB) (*models.QueryResult, error) {
+		return execBulk(ctx, db, mysqlDialect, b, startTime)
+	})
+}
+
+// mysqlValue converts MySQL driver values into JSON-friendly values.
+// Queries without parameters use the text protocol, where every column
+// arrives as []byte, so numbers are parsed back based on the column type.
+func mysqlValue(ct *sql.ColumnType, val any) (any, error) {
+	b, ok := val.([]byte)
+	if !ok {
+		return val, nil
+	}
+
+	typeName := strings.TrimPrefix(ct.DatabaseTypeName(), "UNSIGNED ")
+	switch typeName {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		if n, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(string(b), 10, 64); err == nil {
+			return n, nil
+		}
+	case "FLOAT", "DOUBLE":
+		if f, err := strconv.ParseFloat(string(b), 64); err == nil {
+			return f, nil
+		}
+	case "JSON":
+		if json.Valid(b) {
+			return json.RawMessage(b), nil
+		}
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+		// Left as []byte so encoding/json emits base64
+		return b, nil
+	}
+
+	// DECIMAL stays a string to avoid losing precision
+	return string(b), nil
+}
+
+// mysqlQuote quotes an identifier with backticks
+func mysqlQuote(name string) string {
+	return "`" + name + "`"
+}
@@ -0,0 +1,272 @@
+//go:build integration
+
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"nexus-query-agent/internal/models"
+)
+
+// mysqlTestDS is the mysql-test service of docker-compose.test.yml
+var mysqlTestDS = models.DatasourceInfo{
+	ID:       1,
+	Type:     "mysql",
+	Host:     "localhost",
+	Port:     3307,
+	Database: "nexus_test",
+	Username: "nexus",
+	Password: "nexus",
+}
+
+const mysqlTestDSN = "nexus:nexus@tcp(localhost:3307)/nexus_test?parseTime=true"
+
+func newTestMySQL(t *testing.T) *MySQLExecutor {
+	t.Helper()
+	limits := testLimits()
+	return NewMySQLExecutor(limits, newTestPools(t, limits))
+}
+
+func TestMySQLExecute(t *testing.T) {
+	setupDB(t, "mysql", mysqlTestDSN,
+		"DROP TABLE IF EXISTS it_mysql_select",
+		"CREATE TABLE it_mysql_select (id INT PRIMARY KEY, name VARCHAR(50))",
+		"INSERT INTO it_mysql_select VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e')",
+	)
+	e := newTestMySQL(t)
+	ctx := context.Background()
+	ds := mysqlTestDS
+
+	result, err := e.Execute(ctx, &ds, "SELECT id, name FROM it_mysql_select ORDER BY id", nil, Paging{Page: 2, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success {
+		t.Fatalf("Execute failed: %s", result.Error)
+	}
+	if got := rowIDs(result.Data); !reflect.DeepEqual(got, []any{int64(3), int64(4)}) {
+		t.Errorf("page 2 ids = %v, want [3 4]", got)
+	}
+	p := result.Pagination
+	if p.TotalRows != 5 || p.TotalPages != 3 || !p.HasMore || p.TotalKind != models.TotalExact {
+		t.Errorf("pagination = %+v, want 5 rows in 3 pages with more", p)
+	}
+
+	// JSON numbers arrive as float64 and are bound as integers
+	result, err = e.Execute(ctx, &ds, "SELECT id FROM it_mysql_select WHERE id > ? ORDER BY id", []any{float64(3)}, Paging{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rowIDs(result.Data); !reflect.DeepEqual(got, []any{int64(4), int64(5)}) {
+		t.Errorf("ids > 3 = %v, want [4 5]", got)
+	}
+
+	result, err = e.Execute(ctx, &ds, "SELECT 1; DELETE FROM it_mysql_select", nil, Paging{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || !strings.Contains(result.Error, "Query rejected") {
+		t.Errorf("multi-statement SELECT = %+v, want rejected", result)
+	}
+
+	result, err = e.Execute(ctx, &ds, "SELECT * FROM it_mysql_missing", nil, Paging{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || !strings.Contains(result.Error, "Query failed") {
+		t.Errorf("SELECT from missing table = %+v, want failed", result)
+	}
+}
+
+func TestMySQLExecuteDML(t *testing.T) {
+	db := setupDB(t, "mysql", mysqlTestDSN,
+		"DROP TABLE IF EXISTS it_mysql_dml",
+		"CREATE TABLE it_mysql_dml (id INT PRIMARY KEY, name VARCHAR(50))",
+	)
+	e := newTestMySQL(t)
+	ctx := context.Background()
+	ds := mysqlTestDS
+
+	tests := []struct {
+		queryType string
+		query     string
+		params    []any
+		ok        bool
+		affected  int64
+	}{
+		{"insert", "INSERT INTO it_mysql_dml VALUES (?, ?), (?, ?)", []any{float64(1), "a", float64(2), "b"}, true, 2},
+		{"update", "UPDATE it_mysql_dml SET name = ? WHERE id = ?", []any{"z", float64(2)}, true, 1},
+		{"insert", "INSERT INTO it_mysql_dml VALUES (3, 'c'), (1, 'duplicate')", nil, false, 0},
+		{"delete", "DELETE FROM it_mysql_dml WHERE id = ?", []any{float64(1)}, true, 1},
+		{"delete", "DROP TABLE it_mysql_dml", nil, false, 0},
+	}
+
+	for _, tt := range tests {
+		result, err := e.ExecuteDML(ctx, &ds, tt.queryType, tt.query, tt.params)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if result.Success != tt.ok {
+			t.Fatalf("%s: success = %v (%s), want %v", tt.query, result.Success, result.Error, tt.ok)
+		}
+		if result.QueryType != tt.queryType {
+			t.Errorf("%s: query type = %q, want %q", tt.query, result.QueryType, tt.queryType)
+		}
+		if result.AffectedRows != tt.affected {
+			t.Errorf("%s: affected rows = %d, want %d", tt.query, result.AffectedRows, tt.affected)
+		}
+	}
+
+	// The failed insert was rolled back as a whole
+	var rows []string
+	r, err := db.Query("SELECT CONCAT(id, '=', name) FROM it_mysql_dml ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for r.Next() {
+		var s string
+		if err := r.Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, s)
+	}
+	if want := []string{"2=z"}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("table rows = %v, want %v", rows, want)
+	}
+}
+
+func TestMySQLUpsert(t *testing.T) {
+	setupDB(t, "mysql", mysqlTestDSN,
+		"DROP TABLE IF EXISTS it_mysql_upsert",
+		"CREATE TABLE it_mysql_upsert (id INT PRIMARY KEY, name VARCHAR(50))",
+		"INSERT INTO it_mysql_upsert VALUES (1, 'a'), (2, 'b')",
+	)
+	e := newTestMySQL(t)
+	ds := mysqlTestDS
+
+	// One row updated, one unchanged, one inserted
+	result, err := e.ExecuteBulk(context.Background(), &ds, &BulkInsert{
+		Table:     "it_mysql_upsert",
+		Columns:   []string{"id", "name"},
+		Rows:      [][]any{{float64(1), "z"}, {float64(2), "b"}, {float64(3), "c"}},
+		ChunkRows: 10,
+		Upsert:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success {
+		t.Fatalf("upsert failed: %s", result.Error)
+	}
+	if result.AffectedRows != 3 {
+		t.Errorf("affected rows = %d, want MySQL's raw count 3 (2 + 0 + 1)", result.AffectedRows)
+	}
+	if result.InsertedRows != nil || result.UpdatedRows != nil {
+		t.Errorf("inserted/updated rows are set, want them left out for MySQL")
+	}
+
+	check, err := e.Execute(context.Background(), &ds, "SELECT id, name FROM it_mysql_upsert ORDER BY id", nil, Paging{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, row := range check.Data {
+		got = append(got, row["name"].(string))
+	}
+	if want := []string{"z", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("names = %v, want %v", got, want)
+	}
+}
+
+func TestMySQLValueTypes(t *testing.T) {
+	setupDB(t, "mysql", mysqlTestDSN,
+		"DROP TABLE IF EXISTS it_mysql_types",
+		`CREATE TABLE it_mysql_types (
+			id   INT PRIMARY KEY,
+			ti   TINYINT,
+			bi   BIGINT,
+			ubi  BIGINT UNSIGNED,
+			yr   YEAR,
+			dbl  DOUBLE,
+			dec1 DECIMAL(20,4),
+			js   JSON,
+			vb   VARBINARY(4),
+			vc   VARCHAR(20),
+			dt   DATETIME,
+			nul  INT
+		)`,
+		`INSERT INTO it_mysql_types VALUES (
+			1, -5, -9223372036854775808, 18446744073709551615, 2024, 1.5,
+			'12345678901234.5678', '{"a": [1, 2]}', X'00FF', 'text',
+			'2024-02-29 13:45:00', NULL
+		)`,
+	)
+	e := newTestMySQL(t)
+	ds := mysqlTestDS
+
+	want := map[string]any{
+		"id":   int64(1),
+		"ti":   int64(-5),
+		"bi":   int64(-9223372036854775808),
+		"ubi":  uint64(18446744073709551615),
+		"yr":   int64(2024),
+		"dbl":  float64(1.5),
+		"dec1": "12345678901234.5678",
+		"js":   json.RawMessage(`{"a": [1, 2]}`),
+		"vb":   []byte{0x00, 0xFF},
+		"vc":   "text",
+		"dt":   time.Date(2024, 2, 29, 13, 45, 0, 0, time.UTC),
+		"nul":  nil,
+	}
+
+	// Without params the driver uses the text protocol, with params the
+	// binary protocol; both must map to the same values
+	queries := []struct {
+		name   string
+		query  string
+		params []any
+	}{
+		{"text protocol", "SELECT * FROM it_mysql_types", nil},
+		{"binary protocol", "SELECT * FROM it_mysql_types WHERE id = ?", []any{float64(1)}},
+	}
+
+	for _, q := range queries {
+		t.Run(q.name, func(t *testing.T) {
+			result, err := e.Execute(context.Background(), &ds, q.query, q.params, Paging{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Success || len(result.Data) != 1 {
+				t.Fatalf("Execute = %+v, want one row", result)
+			}
+			row := result.Data[0]
+			for col, w := range want {
+				got := row[col]
+				if wt, ok := w.(time.Time); ok {
+					if gt, ok := got.(time.Time); !ok || !gt.Equal(wt) {
+						t.Errorf("%s = %#v, want %v", col, got, wt)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(got, w) {
+					t.Errorf("%s = %#v (%T), want %#v (%T)", col, got, got, w, w)
+				}
+			}
+		})
+	}
+}
+
+// rowIDs returns the id column of each row
+func rowIDs(data []map[string]any) []any {
+	ids := make([]any, len(data))
+	for i, row := range data {
+		ids[i] = row["id"]
+	}
+	return ids
+}
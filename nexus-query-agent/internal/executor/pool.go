@@ -0,0 +1,253 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"nexus-query-agent/internal/config"
+	"nexus-query-agent/internal/models"
+)
+
+// PoolManager caches database handles per datasource so that consecutive
+// requests reuse open sessions instead of logging on every time
+type PoolManager struct {
+	limits *config.LimitsConfig
+	logger *slog.Logger
+	mu     sync.Mutex
+	pools  map[string]*pool
+	done   chan struct{}
+	once   sync.Once
+}
+
+// pool is a cached *sql.DB together with the credentials it was opened with.
+// refs counts the requests using it; it is only closed once they all released it.
+type pool struct {
+	id       int64
+	typ      string
+	db       *sql.DB
+	refs     int
+	lastUsed time.Time
+	closing  bool // Dropped from the cache; close on the last release
+}
+
+// NewPoolManager creates a pool manager and starts idle eviction
+func NewPoolManager(limits *config.LimitsConfig, logger *slog.Logger) *PoolManager {
+	m := &PoolManager{
+		limits: limits,
+		logger: logger,
+		pools:  make(map[string]*pool),
+		done:   make(chan struct{}),
+	}
+	go m.evictLoop()
+	return m
+}
+
+// Get returns a pooled handle for the datasource, opening one if needed, and
+// a release func the caller must call once done with the handle. Pools are
+// keyed by credentials as well: when a datasource ID arrives with new
+// credentials, its old pool is dropped and closed as soon as requests still
+// using it are done, so a password rotation takes effect immediately.
+func (m *PoolManager) Get(ctx context.Context, ds *models.DatasourceInfo, driverName, dsn string) (*sql.DB, func(), error) {
+	key := poolKey(ds)
+
+	m.mu.Lock()
+	if p, ok := m.pools[key]; ok {
+		p.refs++
+		p.lastUsed = time.Now()
+		m.mu.Unlock()
+		return p.db, m.releaser(p), nil
+	}
+
+	var stale []*pool
+	for oldKey, p := range m.pools {
+		if p.typ == ds.Type && p.id == ds.ID {
+			m.logger.Info("Credentials changed, closing connection pool", "datasource_id", ds.ID)
+			if m.dropLocked(oldKey, p) {
+				stale = append(stale, p)
+			}
+		}
+	}
+	for _, p := range stale {
+		go p.db.Close()
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, nil, err
+	}
+	db.SetMaxOpenConns(m.limits.MaxOpenConns)
+	db.SetMaxIdleConns(m.limits.MaxIdleConns)
+	db.SetConnMaxIdleTime(m.limits.ConnMaxIdleTime)
+
+	p := &pool{id: ds.ID, typ: ds.Type, db: db, refs: 1, lastUsed: time.Now()}
+	m.pools[key] = p
+	m.mu.Unlock()
+
+	release := m.releaser(p)
+
+	// Verify the new pool can log on before handing it out
+	if err := db.PingContext(ctx); err != nil {
+		m.remove(key, p)
+		release()
+		return nil, nil, err
+	}
+
+	m.logger.Info("Opened connection pool",
+		"datasource_id", ds.ID, "datasource_type", ds.Type, "host", ds.Host, "port", ds.Port)
+	return db, release, nil
+}
+
+// releaser returns the release func of one Get of p. A release marks the
+// pool as used, so a long-running query keeps its pool from being evicted.
+func (m *PoolManager) releaser(p *pool) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { m.release(p, true) })
+	}
+}
+
+// release drops a reference to p, marking it used if touch is set, and closes
+// a pool dropped from the cache once its last user is done
+func (m *PoolManager) release(p *pool, touch bool) {
+	m.mu.Lock()
+	p.refs--
+	if touch {
+		p.lastUsed = time.Now()
+	}
+	closeNow := p.closing && p.refs == 0
+	m.mu.Unlock()
+
+	if closeNow {
+		p.db.Close()
+	}
+}
+
+// ProbeResult is the outcome of pinging one pooled datasource
+type ProbeResult struct {
+	DatasourceID int64  `json:"datasource_id"`
+	Type         string `json:"type"`
+	OK           bool   `json:"ok"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Probe pings every pooled datasource. Datasources are not configured on the
+// agent, so only those used recently (and not yet evicted) are probed.
+func (m *PoolManager) Probe(ctx context.Context) []ProbeResult {
+	m.mu.Lock()
+	targets := make([]*pool, 0, len(m.pools))
+	for _, p := range m.pools {
+		// Hold a reference so the pool is not closed under the ping, but do
+		// not count the probe as a use that keeps the pool from eviction
+		p.refs++
+		targets = append(targets, p)
+	}
+	m.mu.Unlock()
+
+	results := make([]ProbeResult, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer m.release(t, false)
+			results[i] = ProbeResult{DatasourceID: t.id, Type: t.typ, OK: true}
+			if err := t.db.PingContext(ctx); err != nil {
+				results[i].OK = false
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Close stops idle eviction and closes all pools
+func (m *PoolManager) Close() {
+	m.once.Do(func() { close(m.done) })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, p := range m.pools {
+		p.db.Close()
+		delete(m.pools, key)
+	}
+}
+
+// remove drops p from the cache if it is still current and closes it, or
+// leaves closing it to the last release while requests still use it
+func (m *PoolManager) remove(key string, p *pool) {
+	m.mu.Lock()
+	closeNow := m.dropLocked(key, p)
+	m.mu.Unlock()
+
+	if closeNow {
+		p.db.Close()
+	}
+}
+
+// dropLocked is remove with m.mu held; it reports whether the caller must
+// close p now
+func (m *PoolManager) dropLocked(key string, p *pool) bool {
+	if m.pools[key] == p {
+		delete(m.pools, key)
+	}
+	p.closing = true
+	return p.refs == 0
+}
+
+// evictLoop periodically closes pools that have not been used recently
+func (m *PoolManager) evictLoop() {
+	ticker := time.NewTicker(m.limits.PoolIdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.evictIdle()
+		}
+	}
+}
+
+// evictIdle closes unused pools idle for longer than the configured timeout
+func (m *PoolManager) evictIdle() {
+	cutoff := time.Now().Add(-m.limits.PoolIdleTimeout)
+
+	var idle []*pool
+	m.mu.Lock()
+	for key, p := range m.pools {
+		if p.refs == 0 && p.lastUsed.Before(cutoff) {
+			m.logger.Info("Closing idle connection pool", "pool", key)
+			idle = append(idle, p)
+			delete(m.pools, key)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, p := range idle {
+		p.db.Close()
+	}
+}
+
+// poolKey identifies the pool of a datasource and its credentials
+func poolKey(ds *models.DatasourceInfo) string {
+	return fmt.Sprintf("%s:%d:%s", ds.Type, ds.ID, credentialFingerprint(ds))
+}
+
+// credentialFingerprint hashes the connection details of a datasource
+func credentialFingerprint(ds *models.DatasourceInfo) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%s\x00%s\x00%s\x00%s",
+		ds.Host, ds.Port, ds.DatabaseName, ds.Database, ds.Username, ds.Password)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,316 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"nexus-query-agent/internal/config"
+	"nexus-query-agent/internal/metrics"
+	"nexus-query-agent/internal/models"
+)
+
+// PostgresExecutor handles PostgreSQL query execution with pooled connections
+type PostgresExecutor struct {
+	limits *config.LimitsConfig
+	pools  *PoolManager
+}
+
+func init() {
+	Register(Driver{
+		Type: "postgres",
+		Factory: func(limits *config.LimitsConfig, pools *PoolManager) Executor {
+			return NewPostgresExecutor(limits, pools)
+		},
+		Prototype:    (*PostgresExecutor)(nil),
+		Capabilities: []Capability{CapSelect, CapStreaming, CapDML, CapTransactions, CapBulk, CapUpsert},
+	})
+}
+
+// NewPostgresExecutor creates a new PostgreSQL executor
+func NewPostgresExecutor(limits *config.LimitsConfig, pools *PoolManager) *PostgresExecutor {
+	return &PostgresExecutor{
+		limits: limits,
+		pools:  pools,
+	}
+}
+
+// postgresDialect maps PostgreSQL types to native JSON values
+var postgresDialect = &dialect{
+	convert:      postgresValue,
+	typeName:     postgresTypeName,
+	placeholder:  func(n int) string { return "$" + strconv.Itoa(n) },
+	upsert:       postgresUpsert,
+	upsertCounts: true,
+	syntax:       syntaxPostgres,
+}
+
+// connect returns a pooled connection for the datasource and its release func
+func (e *PostgresExecutor) connect(ctx context.Context, ds *models.DatasourceInfo) (*sql.DB, func(), error) {
+	dbName := ds.Database
+	if dbName == "" {
+		dbName = ds.DatabaseName
+	}
+
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(ds.Username, ds.Password),
+		Host:   fmt.Sprintf("%s:%d", ds.Host, ds.Port),
+		Path:   "/" + dbName,
+	}
+
+	return e.pools.Get(ctx, ds, "pgx", dsn.String())
+}
+
+// Execute runs a query using datasource info from the request
+func (e *PostgresExecutor) Execute(ctx context.Context, ds *models.DatasourceInfo, query string, params []any, paging Paging) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "select", startTime)
+
+	return withDB(ctx, e.connect, ds, "select", func(db *sql.DB) (*models.QueryResult, error) {
+		paging = normalizePage(paging, e.limits.MaxRows)
+		result, err := selectPage(ctx, db, postgresDialect, ds, query, params, paging)
+		if err != nil {
+			return nil, err
+		}
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+
+		return result, nil
+	})
+}
+
+// ExecuteStream runs a SELECT and hands rows to w as they are read.
+// The returned result carries pagination and timing but no rows.
+func (e *PostgresExecutor) ExecuteStream(ctx context.Context, ds *models.DatasourceInfo, query string, params []any, paging Paging, w RowWriter) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "select", startTime)
+
+	return withDB(ctx, e.connect, ds, "select", func(db *sql.DB) (*models.QueryResult, error) {
+		paging = normalizePage(paging, e.limits.MaxStreamRows)
+		result, err := streamPage(ctx, db, postgresDialect, ds, query, params, paging, w)
+		if err != nil {
+			return nil, err
+		}
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+
+		return result, nil
+	})
+}
+
+// ExecuteDML executes INSERT, UPDATE, DELETE with transaction handling
+func (e *PostgresExecutor) ExecuteDML(ctx context.Context, ds *models.DatasourceInfo, queryType, query string, params []any) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, queryType, startTime)
+
+	return withDB(ctx, e.connect, ds, queryType, func(db *sql.DB) (*models.QueryResult, error) {
+		return execDML(ctx, db, postgresDialect, queryType, query, params, startTime)
+	})
+}
+
+// ExecuteTransaction runs statements in order in a single transaction
+func (e *PostgresExecutor) ExecuteTransaction(ctx context.Context, ds *models.DatasourceInfo, statements []models.Statement) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "transaction", startTime)
+
+	return withDB(ctx, e.connect, ds, "transaction", func(db *sql.DB) (*models.QueryResult, error) {
+		return execTransaction(ctx, db, postgresDialect, statements, startTime)
+	})
+}
+
+// ExecuteBulk inserts or upserts a row matrix in chunks
+func (e *PostgresExecutor) ExecuteBulk(ctx context.Context, ds *models.DatasourceInfo, b *BulkInsert) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, b.queryType(), startTime)
+
+	return withDB(ctx, e.connect, ds, b.queryType(), func(db *sql.DB) (*models.QueryResult, error) {
+		return execBulk(ctx, db, postgresDialect, b, startTime)
+	})
+}
+
+// postgresTypeName reports array types as ELEM[] instead of the internal _ELEM name
+func postgresTypeName(name string) string {
+	if strings.HasPrefix(name, "_") {
+		return strings.TrimPrefix(name, "_") + "[]"
+	}
+	return name
+}
+
+// postgresValue converts pgx driver values into JSON-friendly values.
+// pgx returns numeric, uuid and arrays in their text form and json/jsonb
+// as raw bytes; timestamps already arrive as time.Time.
+func postgresValue(ct *sql.ColumnType, val any) (any, error) {
+	typeName := ct.DatabaseTypeName()
+
+	if strings.HasPrefix(typeName, "_") {
+		if s, ok := val.(string); ok {
+			elemType := strings.TrimPrefix(typeName, "_")
+			if arr, err := parsePostgresArray(s, elemType); err == nil {
+				return arr, nil
+			}
+			return s, nil
+		}
+	}
+
+	switch v := val.(type) {
+	case []byte:
+		if typeName == "JSON" || typeName == "JSONB" {
+			if json.Valid(v) {
+				return json.RawMessage(v), nil
+			}
+			return string(v), nil
+		}
+		// bytea is left as []byte so encoding/json emits base64
+		return v, nil
+	case string:
+		return postgresScalar(v, typeName), nil
+	}
+
+	return val, nil
+}
+
+// postgresScalar converts the text form of a scalar value based on its type
+func postgresScalar(s, typeName string) any {
+	switch typeName {
+	case "NUMERIC":
+		// json.Number keeps the exact digits; NaN and Infinity stay strings
+		if _, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "IN") {
+			return json.Number(s)
+		}
+		return s
+	case "INT2", "INT4", "INT8":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case "FLOAT4", "FLOAT8":
+		// NaN and Infinity have no JSON number and stay strings
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	case "BOOL":
+		return s == "t" || s == "true"
+	case "JSON", "JSONB":
+		if json.Valid([]byte(s)) {
+			return json.RawMessage(s)
+		}
+	}
+	return s
+}
+
+// parsePostgresArray parses the text representation of a PostgreSQL array
+// such as {1,2,NULL} or {{"a b",c},{d,e}} into nested []any
+func parsePostgresArray(s, elemType string) ([]any, error) {
+	p := &pgArrayParser{src: s, elemType: elemType}
+	// Arrays with explicit bounds are prefixed with e.g. [1:3]=
+	if strings.HasPrefix(s, "[") {
+		if i := strings.Index(s, "="); i >= 0 {
+			p.pos = i + 1
+		}
+	}
+	arr, err := p.parseArray()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.src) {
+		return nil, fmt.Errorf("unexpected trailing data at %d", p.pos)
+	}
+	return arr, nil
+}
+
+// pgArrayParser is a small recursive descent parser for PostgreSQL array literals
+type pgArrayParser struct {
+	src      string
+	pos      int
+	elemType string
+}
+
+func (p *pgArrayParser) parseArray() ([]any, error) {
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		return nil, fmt.Errorf("expected '{' at %d", p.pos)
+	}
+	p.pos++
+
+	result := make([]any, 0)
+	if p.pos < len(p.src) && p.src[p.pos] == '}' {
+		p.pos++
+		return result, nil
+	}
+
+	for {
+		if p.pos >= len(p.src) {
+			return nil, fmt.Errorf("unterminated array")
+		}
+
+		switch p.src[p.pos] {
+		case '{':
+			sub, err := p.parseArray()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, sub)
+		case '"':
+			elem, err := p.parseQuoted()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, postgresScalar(elem, p.elemType))
+		default:
+			elem := p.parseUnquoted()
+			if elem == "NULL" {
+				result = append(result, nil)
+			} else {
+				result = append(result, postgresScalar(elem, p.elemType))
+			}
+		}
+
+		if p.pos >= len(p.src) {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return result, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos], p.pos)
+		}
+	}
+}
+
+func (p *pgArrayParser) parseQuoted() (string, error) {
+	p.pos++ // opening quote
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch c {
+		case '\\':
+			p.pos++
+			if p.pos < len(p.src) {
+				sb.WriteByte(p.src[p.pos])
+			}
+		case '"':
+			p.pos++
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+		p.pos++
+	}
+	return "", fmt.Errorf("unterminated quoted element")
+}
+
+func (p *pgArrayParser) parseUnquoted() string {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != ',' && p.src[p.pos] != '}' {
+		p.pos++
+	}
+	return strings.TrimSpace(p.src[start:p.pos])
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParsePostgresArray(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		elemType string
+		want     []any
+	}{
+		{"empty", "{}", "INT4", []any{}},
+		{"integers", "{1,2,NULL}", "INT4", []any{int64(1), int64(2), nil}},
+		{"nested", "{{1,2},{3,4}}", "INT8", []any{[]any{int64(1), int64(2)}, []any{int64(3), int64(4)}}},
+		{"nested empty", "{{},{}}", "INT4", []any{[]any{}, []any{}}},
+		{"quoted", `{"a b",c,"d,e","{f}"}`, "TEXT", []any{"a b", "c", "d,e", "{f}"}},
+		{"escaped quote", `{"say \"hi\"","back\\slash"}`, "TEXT", []any{`say "hi"`, `back\slash`}},
+		{"null and quoted NULL", `{NULL,"NULL",null}`, "TEXT", []any{nil, "NULL", "null"}},
+		{"empty string", `{"",x}`, "TEXT", []any{"", "x"}},
+		{"bounds", "[1:3]={1,2,3}", "INT4", []any{int64(1), int64(2), int64(3)}},
+		{"bounds zero based", "[0:1]={a,b}", "TEXT", []any{"a", "b"}},
+		{"bounds nested", "[0:1][1:2]={{1,2},{3,4}}", "INT4", []any{[]any{int64(1), int64(2)}, []any{int64(3), int64(4)}}},
+		{"bounds quoted equals", `[1:1]={"a=b"}`, "TEXT", []any{"a=b"}},
+		{"numeric", "{1.50,NaN,Infinity,-Infinity,NULL}", "NUMERIC", []any{json.Number("1.50"), "NaN", "Infinity", "-Infinity", nil}},
+		{"bool", "{t,f}", "BOOL", []any{true, false}},
+		{"float", "{1.5,-2}", "FLOAT8", []any{1.5, float64(-2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePostgresArray(tt.src, tt.elemType)
+			if err != nil {
+				t.Fatalf("parsePostgresArray(%q) error: %v", tt.src, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePostgresArray(%q) = %#v, want %#v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePostgresArrayErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"1,2",
+		"{1,2",
+		"{{1,2}",
+		`{"a`,
+		`{"a\"}`,
+		"{1}x",
+		"[1:2]",
+	} {
+		if got, err := parsePostgresArray(src, "INT4"); err == nil {
+			t.Errorf("parsePostgresArray(%q) = %#v, want error", src, got)
+		}
+	}
+}
+
+func TestPostgresScalar(t *testing.T) {
+	tests := []struct {
+		src      string
+		typeName string
+		want     any
+	}{
+		{"12.30", "NUMERIC", json.Number("12.30")},
+		{"-0.000001", "NUMERIC", json.Number("-0.000001")},
+		{"123456789012345678901234567890", "NUMERIC", json.Number("123456789012345678901234567890")},
+		{"NaN", "NUMERIC", "NaN"},
+		{"Infinity", "NUMERIC", "Infinity"},
+		{"-Infinity", "NUMERIC", "-Infinity"},
+		{"42", "INT8", int64(42)},
+		{"-7", "INT2", int64(-7)},
+		{"x", "INT4", "x"},
+		{"1.25", "FLOAT4", 1.25},
+		{"NaN", "FLOAT8", "NaN"},
+		{"-Infinity", "FLOAT8", "-Infinity"},
+		{"t", "BOOL", true},
+		{"f", "BOOL", false},
+		{`{"a":1}`, "JSONB", json.RawMessage(`{"a":1}`)},
+		{`{"a":`, "JSON", `{"a":`},
+		{"NULL", "TEXT", "NULL"},
+		{"6d1f2c3e-0000-4000-8000-000000000000", "UUID", "6d1f2c3e-0000-4000-8000-000000000000"},
+	}
+
+	for _, tt := range tests {
+		got := postgresScalar(tt.src, tt.typeName)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("postgresScalar(%q, %s) = %#v, want %#v", tt.src, tt.typeName, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"nexus-query-agent/internal/config"
+	"nexus-query-agent/internal/models"
+)
+
+// Capability is something a datasource driver can execute
+type Capability string
+
+const (
+	CapSelect       Capability = "select"       // Executor
+	CapStreaming    Capability = "streaming"    // StreamExecutor
+	CapDML          Capability = "dml"          // DMLExecutor
+	CapTransactions Capability = "transactions" // TransactionExecutor
+	CapBulk         Capability = "bulk"         // BulkExecutor
+	CapUpsert       Capability = "upsert"       // BulkExecutor with BulkInsert.Upsert
+	CapMetadata     Capability = "metadata"     // Schema browsing
+	CapProcedures   Capability = "procedures"   // Stored procedure calls
+)
+
+// queryTypes maps capabilities to the request query types they enable
+var queryTypes = map[Capability][]string{
+	CapSelect:       {"select"},
+	CapDML:          {"insert", "update", "delete"},
+	CapTransactions: {"transaction"},
+	CapBulk:         {"bulk_insert"},
+	CapUpsert:       {"upsert"},
+	CapMetadata:     {"list_schemas", "list_tables", "list_views", "describe_table"},
+	CapProcedures:   {"call"},
+}
+
+// writeCapabilities are the capabilities whose query types can change data
+var writeCapabilities = []Capability{CapDML, CapTransactions, CapBulk, CapUpsert, CapProcedures}
+
+// IsWrite reports whether a query type can change data
+func IsWrite(queryType string) bool {
+	for _, c := range writeCapabilities {
+		if slices.Contains(queryTypes[c], queryType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Factory creates an executor for one datasource type
+type Factory func(limits *config.LimitsConfig, pools *PoolManager) Executor
+
+// Driver is a datasource type registered with the executor registry
+type Driver struct {
+	Type    string // Datasource type as sent by Nexus, e.g. "sap"
+	Factory Factory
+	// Prototype is a nil pointer of the executor type, e.g.
+	// (*MySQLExecutor)(nil), against which Register checks Capabilities
+	// without building an executor
+	Prototype    Executor
+	Capabilities []Capability
+}
+
+// Has reports whether the driver declares the capability
+func (d Driver) Has(c Capability) bool {
+	return slices.Contains(d.Capabilities, c)
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register makes a datasource driver available to NewExecutor. Drivers call
+// it from init. It panics if the type is registered twice or the prototype
+// does not implement the interfaces its capabilities require.
+func Register(d Driver) {
+	if d.Type == "" || d.Factory == nil || d.Prototype == nil {
+		panic("executor: Register with empty type, nil factory or nil prototype")
+	}
+
+	exec := d.Prototype
+	for _, c := range d.Capabilities {
+		var ok bool
+		switch c {
+		case CapSelect:
+			ok = true // Every Executor can select
+		case CapStreaming:
+			_, ok = exec.(StreamExecutor)
+		case CapDML:
+			_, ok = exec.(DMLExecutor)
+		case CapTransactions:
+			_, ok = exec.(TransactionExecutor)
+		case CapBulk, CapUpsert:
+			_, ok = exec.(BulkExecutor)
+		case CapMetadata:
+			_, ok = exec.(MetadataExecutor)
+		case CapProcedures:
+			_, ok = exec.(ProcedureExecutor)
+		default:
+			ok = true
+		}
+		if !ok {
+			panic(fmt.Sprintf("executor: %s driver declares %q but does not implement it", d.Type, c))
+		}
+	}
+
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if _, dup := drivers[d.Type]; dup {
+		panic("executor: Register called twice for " + d.Type)
+	}
+	drivers[d.Type] = d
+}
+
+// Lookup returns the driver registered for a datasource type
+func Lookup(dsType string) (Driver, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	d, ok := drivers[dsType]
+	return d, ok
+}
+
+// Drivers returns all registered drivers sorted by type
+func Drivers() []Driver {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	list := make([]Driver, 0, len(drivers))
+	for _, d := range drivers {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type < list[j].Type })
+	return list
+}
+
+// Capabilities reports what each registered datasource type can execute.
+// readOnly leaves out the capabilities that can change data.
+func Capabilities(readOnly bool) []models.DatasourceCapabilities {
+	list := Drivers()
+	caps := make([]models.DatasourceCapabilities, 0, len(list))
+	for _, d := range list {
+		c := models.DatasourceCapabilities{
+			Type:      d.Type,
+			Streaming: d.Has(CapStreaming),
+		}
+		for _, capability := range d.Capabilities {
+			if readOnly && slices.Contains(writeCapabilities, capability) {
+				continue
+			}
+			c.QueryTypes = append(c.QueryTypes, queryTypes[capability]...)
+			c.Features = append(c.Features, string(capability))
+		}
+		caps = append(caps, c)
+	}
+	return caps
+}
+
+// agentFeatures are request features the agent supports for every datasource
+// type: bind params, per-request timeouts, cancel_query, keyset cursors,
+// count_mode and the SQL guard
+var agentFeatures = []string{"params", "timeout", "cancel", "keyset", "count_mode", "sql_guard"}
+
+// AgentCapabilities builds everything the agent advertises at registration
+// from the registered drivers and limits. Features lists agentFeatures, the
+// capabilities of any registered driver and the query guard settings.
+func AgentCapabilities(limits *config.LimitsConfig) *models.AgentCapabilities {
+	caps := &models.AgentCapabilities{
+		ProtocolVersion: models.ProtocolVersion,
+		Datasources:     Capabilities(limits.DisableDML),
+		Encodings:       []string{"json"},
+		Features:        slices.Clone(agentFeatures),
+	}
+
+	for _, ds := range caps.Datasources {
+		for _, f := range ds.Features {
+			if !slices.Contains(caps.Features, f) {
+				caps.Features = append(caps.Features, f)
+			}
+		}
+		if ds.Streaming && !slices.Contains(caps.Encodings, "json_stream") {
+			caps.Encodings = append(caps.Encodings, "json_stream")
+		}
+	}
+
+	if limits.ReadOnlySelect {
+		caps.Features = append(caps.Features, "read_only_select")
+	}
+	if limits.DisableDML {
+		caps.Features = append(caps.Features, "dml_disabled")
+	}
+	return caps
+}
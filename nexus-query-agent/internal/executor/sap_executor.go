@@ -1,34 +1,45 @@
 package executor
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"time"
 
 	_ "github.com/SAP/go-hdb/driver"
 
 	"nexus-query-agent/internal/config"
+	"nexus-query-agent/internal/metrics"
 	"nexus-query-agent/internal/models"
 )
 
-// SapExecutor handles SAP HANA query execution with dynamic connections
+// SapExecutor handles SAP HANA query execution with pooled connections
 type SapExecutor struct {
 	limits *config.LimitsConfig
+	pools  *PoolManager
+}
+
+func init() {
+	Register(Driver{
+		Type: "sap",
+		Factory: func(limits *config.LimitsConfig, pools *PoolManager) Executor {
+			return NewSapExecutor(limits, pools)
+		},
+		Prototype:    (*SapExecutor)(nil),
+		Capabilities: []Capability{CapSelect, CapStreaming, CapDML, CapTransactions, CapBulk, CapUpsert, CapMetadata, CapProcedures},
+	})
 }
 
 // NewSapExecutor creates a new SAP HANA executor
-func NewSapExecutor(limits *config.LimitsConfig) *SapExecutor {
+func NewSapExecutor(limits *config.LimitsConfig, pools *PoolManager) *SapExecutor {
 	return &SapExecutor{
 		limits: limits,
+		pools:  pools,
 	}
 }
 
-// Execute runs a query using datasource info from the request
-func (e *SapExecutor) Execute(ds *models.DatasourceInfo, query string, page, limit int) (*models.QueryResult, error) {
-	startTime := time.Now()
-
-	// Connect to SAP HANA using provided credentials
+// connect returns a pooled connection for the datasource and its release func
+func (e *SapExecutor) connect(ctx context.Context, ds *models.DatasourceInfo) (*sql.DB, func(), error) {
 	// For SAP HANA MDC (Multitenant), add databaseName parameter
 	var dsn string
 	if ds.DatabaseName != "" {
@@ -39,228 +50,71 @@ func (e *SapExecutor) Execute(ds *models.DatasourceInfo, query string, page, lim
 			ds.Username, ds.Password, ds.Host, ds.Port)
 	}
 
-	db, err := sql.Open("hdb", dsn)
-	if err != nil {
-		return &models.QueryResult{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to connect: %v", err),
-		}, nil
-	}
-	defer db.Close()
-
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return &models.QueryResult{
-			Success: false,
-			Error:   fmt.Sprintf("Connection failed: %v", err),
-		}, nil
-	}
-
-	log.Printf("INFO: Connected to SAP HANA at %s:%d (database: %s)", ds.Host, ds.Port, ds.DatabaseName)
-
-	// Apply limits
-	if limit <= 0 || limit > e.limits.MaxRows {
-		limit = e.limits.MaxRows
-	}
-	if page <= 0 {
-		page = 1
-	}
-
-	offset := (page - 1) * limit
-
-	// Wrap query with pagination (SAP HANA syntax)
-	paginatedQuery := fmt.Sprintf(`
-		SELECT * FROM (%s) AS subquery
-		LIMIT %d OFFSET %d
-	`, query, limit, offset)
-
-	// Execute query
-	rows, err := db.Query(paginatedQuery)
-	if err != nil {
-		return &models.QueryResult{
-			Success: false,
-			Error:   fmt.Sprintf("Query failed: %v", err),
-		}, nil
-	}
-	defer rows.Close()
-
-	// Get column info
-	columnTypes, err := rows.ColumnTypes()
-	if err != nil {
-		return &models.QueryResult{
-			Success: false,
-			Error:   fmt.Sprintf("Failed to get columns: %v", err),
-		}, nil
-	}
+	return e.pools.Get(ctx, ds, "hdb", dsn)
+}
 
-	columns := make([]models.ColumnInfo, len(columnTypes))
-	for i, ct := range columnTypes {
-		nullable, _ := ct.Nullable()
-		columns[i] = models.ColumnInfo{
-			Name:     ct.Name(),
-			Type:     ct.DatabaseTypeName(),
-			Nullable: nullable,
+// Execute runs a query using datasource info from the request
+func (e *SapExecutor) Execute(ctx context.Context, ds *models.DatasourceInfo, query string, params []any, paging Paging) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "select", startTime)
+
+	return withDB(ctx, e.connect, ds, "select", func(db *sql.DB) (*models.QueryResult, error) {
+		// Apply limits and run the paginated query (SAP HANA supports LIMIT/OFFSET)
+		paging = normalizePage(paging, e.limits.MaxRows)
+		result, err := selectPage(ctx, db, sapDialect(e.limits.MaxLOBBytes), ds, query, params, paging)
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	// Scan rows
-	data := make([]map[string]any, 0)
-	colNames := make([]string, len(columnTypes))
-	for i, ct := range columnTypes {
-		colNames[i] = ct.Name()
-	}
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
 
-	for rows.Next() {
-		values := make([]any, len(colNames))
-		valuePtrs := make([]any, len(colNames))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
+		return result, nil
+	})
+}
 
-		if err := rows.Scan(valuePtrs...); err != nil {
-			log.Printf("ERROR: Failed to scan row: %v", err)
-			continue
-		}
+// ExecuteStream runs a SELECT and hands rows to w as they are read.
+// The returned result carries pagination and timing but no rows.
+func (e *SapExecutor) ExecuteStream(ctx context.Context, ds *models.DatasourceInfo, query string, params []any, paging Paging, w RowWriter) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "select", startTime)
 
-		row := make(map[string]any)
-		for i, col := range colNames {
-			val := values[i]
-			if b, ok := val.([]byte); ok {
-				val = string(b)
-			}
-			row[col] = val
+	return withDB(ctx, e.connect, ds, "select", func(db *sql.DB) (*models.QueryResult, error) {
+		paging = normalizePage(paging, e.limits.MaxStreamRows)
+		result, err := streamPage(ctx, db, sapDialect(e.limits.MaxLOBBytes), ds, query, params, paging, w)
+		if err != nil {
+			return nil, err
 		}
-		data = append(data, row)
-	}
-
-	// Get total count
-	var totalRows int
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS subquery", query)
-	if err := db.QueryRow(countQuery).Scan(&totalRows); err != nil {
-		log.Printf("WARN: Failed to get total count: %v", err)
-		totalRows = len(data)
-	}
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
 
-	totalPages := (totalRows + limit - 1) / limit
-	executionTime := time.Since(startTime).Milliseconds()
-
-	return &models.QueryResult{
-		Success:   true,
-		QueryType: "select",
-		Data:      data,
-		Columns:   columns,
-		Pagination: &models.Pagination{
-			Page:       page,
-			Limit:      limit,
-			TotalRows:  totalRows,
-			TotalPages: totalPages,
-		},
-		ExecutionTimeMs: executionTime,
-	}, nil
+		return result, nil
+	})
 }
 
 // ExecuteDML executes INSERT, UPDATE, DELETE with transaction handling
-func (e *SapExecutor) ExecuteDML(ds *models.DatasourceInfo, queryType, query string, params []any) (*models.QueryResult, error) {
+func (e *SapExecutor) ExecuteDML(ctx context.Context, ds *models.DatasourceInfo, queryType, query string, params []any) (*models.QueryResult, error) {
 	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, queryType, startTime)
 
-	// Build DSN
-	var dsn string
-	if ds.DatabaseName != "" {
-		dsn = fmt.Sprintf("hdb://%s:%s@%s:%d?databaseName=%s",
-			ds.Username, ds.Password, ds.Host, ds.Port, ds.DatabaseName)
-	} else {
-		dsn = fmt.Sprintf("hdb://%s:%s@%s:%d",
-			ds.Username, ds.Password, ds.Host, ds.Port)
-	}
-
-	db, err := sql.Open("hdb", dsn)
-	if err != nil {
-		return &models.QueryResult{
-			Success:   false,
-			QueryType: queryType,
-			Error:     fmt.Sprintf("Failed to connect: %v", err),
-		}, nil
-	}
-	defer db.Close()
-
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return &models.QueryResult{
-			Success:   false,
-			QueryType: queryType,
-			Error:     fmt.Sprintf("Connection failed: %v", err),
-		}, nil
-	}
-
-	log.Printf("INFO: Connected to SAP HANA for %s operation at %s:%d", queryType, ds.Host, ds.Port)
-
-	// Start transaction
-	tx, err := db.Begin()
-	if err != nil {
-		return &models.QueryResult{
-			Success:   false,
-			QueryType: queryType,
-			Error:     fmt.Sprintf("Failed to begin transaction: %v", err),
-		}, nil
-	}
-
-	// Defer rollback in case of panic
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			log.Printf("ERROR: Panic during %s, rolled back: %v", queryType, r)
-		}
-	}()
-
-	log.Printf("INFO: Executing %s with %d params", queryType, len(params))
-
-	// Execute DML query
-	var result sql.Result
-	if len(params) > 0 {
-		result, err = tx.Exec(query, params...)
-	} else {
-		result, err = tx.Exec(query)
-	}
-
-	if err != nil {
-		// Rollback on error
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Printf("ERROR: Rollback failed: %v", rollbackErr)
-		}
-		log.Printf("ERROR: %s failed, rolled back: %v", queryType, err)
-		return &models.QueryResult{
-			Success:         false,
-			QueryType:       queryType,
-			Error:           fmt.Sprintf("%s failed: %v (transaction rolled back)", queryType, err),
-			ExecutionTimeMs: time.Since(startTime).Milliseconds(),
-		}, nil
-	}
+	return withDB(ctx, e.connect, ds, queryType, func(db *sql.DB) (*models.QueryResult, error) {
+		return execDML(ctx, db, sapDialect(e.limits.MaxLOBBytes), queryType, query, params, startTime)
+	})
+}
 
-	// Get affected rows
-	affectedRows, err := result.RowsAffected()
-	if err != nil {
-		log.Printf("WARN: Could not get affected rows: %v", err)
-		affectedRows = 0
-	}
+// ExecuteTransaction runs statements in order in a single transaction
+func (e *SapExecutor) ExecuteTransaction(ctx context.Context, ds *models.DatasourceInfo, statements []models.Statement) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "transaction", startTime)
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return &models.QueryResult{
-			Success:         false,
-			QueryType:       queryType,
-			Error:           fmt.Sprintf("Failed to commit transaction: %v", err),
-			ExecutionTimeMs: time.Since(startTime).Milliseconds(),
-		}, nil
-	}
+	return withDB(ctx, e.connect, ds, "transaction", func(db *sql.DB) (*models.QueryResult, error) {
+		return execTransaction(ctx, db, sapDialect(e.limits.MaxLOBBytes), statements, startTime)
+	})
+}
 
-	executionTime := time.Since(startTime).Milliseconds()
-	log.Printf("INFO: %s completed successfully, %d rows affected in %dms", queryType, affectedRows, executionTime)
+// ExecuteBulk inserts or upserts a row matrix in chunks
+func (e *SapExecutor) ExecuteBulk(ctx context.Context, ds *models.DatasourceInfo, b *BulkInsert) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, b.queryType(), startTime)
 
-	return &models.QueryResult{
-		Success:         true,
-		QueryType:       queryType,
-		AffectedRows:    affectedRows,
-		ExecutionTimeMs: executionTime,
-	}, nil
+	return withDB(ctx, e.connect, ds, b.queryType(), func(db *sql.DB) (*models.QueryResult, error) {
+		return execBulk(ctx, db, sapDialect(e.limits.MaxLOBBytes), b, startTime)
+	})
 }
@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"nexus-query-agent/internal/metrics"
+	"nexus-query-agent/internal/models"
+)
+
+// Catalog queries against the SAP HANA SYS views. Aliases are quoted so the
+// result keys are lower case. An empty schema means the session's CURRENT_SCHEMA.
+const (
+	sapListSchemasQuery = `
+		SELECT SCHEMA_NAME AS "schema_name", SCHEMA_OWNER AS "owner", HAS_PRIVILEGES AS "has_privileges"
+		FROM SYS.SCHEMAS
+		ORDER BY SCHEMA_NAME`
+
+	sapListTablesQuery = `
+		SELECT SCHEMA_NAME AS "schema_name", TABLE_NAME AS "table_name", TABLE_TYPE AS "table_type",
+			IS_TEMPORARY AS "is_temporary", COMMENTS AS "comments"
+		FROM SYS.TABLES
+		WHERE SCHEMA_NAME = %s
+		ORDER BY TABLE_NAME`
+
+	sapListViewsQuery = `
+		SELECT SCHEMA_NAME AS "schema_name", VIEW_NAME AS "view_name", VIEW_TYPE AS "view_type",
+			COMMENTS AS "comments"
+		FROM SYS.VIEWS
+		WHERE SCHEMA_NAME = %s
+		ORDER BY VIEW_NAME`
+
+	// Tables and views share the column listing; primary keys come from SYS.CONSTRAINTS
+	sapDescribeTableQuery = `
+		SELECT c.COLUMN_NAME, c.DATA_TYPE_NAME, c.LENGTH, c.SCALE, c.IS_NULLABLE, c.DEFAULT_VALUE, c.COMMENTS,
+			CASE WHEN pk.COLUMN_NAME IS NULL THEN 'FALSE' ELSE 'TRUE' END
+		FROM (
+			SELECT SCHEMA_NAME, TABLE_NAME, COLUMN_NAME, POSITION, DATA_TYPE_NAME, LENGTH, SCALE,
+				IS_NULLABLE, DEFAULT_VALUE, COMMENTS
+			FROM SYS.TABLE_COLUMNS
+			UNION ALL
+			SELECT SCHEMA_NAME, VIEW_NAME, COLUMN_NAME, POSITION, DATA_TYPE_NAME, LENGTH, SCALE,
+				IS_NULLABLE, DEFAULT_VALUE, COMMENTS
+			FROM SYS.VIEW_COLUMNS
+		) c
+		LEFT JOIN SYS.CONSTRAINTS pk
+			ON pk.SCHEMA_NAME = c.SCHEMA_NAME AND pk.TABLE_NAME = c.TABLE_NAME
+			AND pk.COLUMN_NAME = c.COLUMN_NAME AND pk.IS_PRIMARY_KEY = 'TRUE'
+		WHERE c.SCHEMA_NAME = %s AND c.TABLE_NAME = ?
+		ORDER BY c.POSITION`
+)
+
+// sapSchemaFilter returns the SQL for a schema comparison and its bind params
+func sapSchemaFilter(schema string) (string, []any) {
+	if schema == "" {
+		return "CURRENT_SCHEMA", nil
+	}
+	return "?", []any{schema}
+}
+
+// ListSchemas lists the schemas visible to the datasource user
+func (e *SapExecutor) ListSchemas(ctx context.Context, ds *models.DatasourceInfo, page, limit int) (*models.QueryResult, error) {
+	return e.listCatalog(ctx, ds, "list_schemas", sapListSchemasQuery, nil, page, limit)
+}
+
+// ListTables lists the tables of a schema
+func (e *SapExecutor) ListTables(ctx context.Context, ds *models.DatasourceInfo, schema string, page, limit int) (*models.QueryResult, error) {
+	filter, params := sapSchemaFilter(schema)
+	return e.listCatalog(ctx, ds, "list_tables", fmt.Sprintf(sapListTablesQuery, filter), params, page, limit)
+}
+
+// ListViews lists the views of a schema
+func (e *SapExecutor) ListViews(ctx context.Context, ds *models.DatasourceInfo, schema string, page, limit int) (*models.QueryResult, error) {
+	filter, params := sapSchemaFilter(schema)
+	return e.listCatalog(ctx, ds, "list_views", fmt.Sprintf(sapListViewsQuery, filter), params, page, limit)
+}
+
+// listCatalog runs a paginated catalog query
+func (e *SapExecutor) listCatalog(ctx context.Context, ds *models.DatasourceInfo, queryType, query string, params []any, page, limit int) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, queryType, startTime)
+
+	return withDB(ctx, e.connect, ds, queryType, func(db *sql.DB) (*models.QueryResult, error) {
+		paging := normalizePage(Paging{Page: page, Limit: limit}, e.limits.MaxRows)
+		result, err := selectPage(ctx, db, sapDialect(e.limits.MaxLOBBytes), ds, query, params, paging)
+		if err != nil {
+			return nil, err
+		}
+		result.QueryType = queryType
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+
+		return result, nil
+	})
+}
+
+// DescribeTable returns the columns of a table or view with their type
+// details, defaults and primary key membership
+func (e *SapExecutor) DescribeTable(ctx context.Context, ds *models.DatasourceInfo, schema, table string) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "describe_table", startTime)
+
+	result, err := e.describeTable(ctx, ds, schema, table)
+	if result != nil {
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+	}
+	return result, err
+}
+
+// describeTable reads the column metadata for DescribeTable
+func (e *SapExecutor) describeTable(ctx context.Context, ds *models.DatasourceInfo, schema, table string) (*models.QueryResult, error) {
+	result := &models.QueryResult{QueryType: "describe_table"}
+
+	return withDB(ctx, e.connect, ds, "describe_table", func(db *sql.DB) (*models.QueryResult, error) {
+		filter, params := sapSchemaFilter(schema)
+		rows, err := db.QueryContext(ctx, fmt.Sprintf(sapDescribeTableQuery, filter), append(params, table)...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Error = fmt.Sprintf("Query failed: %v", err)
+			return result, nil
+		}
+		defer rows.Close()
+
+		columns := make([]models.ColumnInfo, 0)
+		for rows.Next() {
+			var (
+				col                       models.ColumnInfo
+				length, scale             sql.NullInt64
+				nullable, primaryKey      string
+				defaultValue, description sql.NullString
+			)
+			if err := rows.Scan(&col.Name, &col.Type, &length, &scale, &nullable, &defaultValue, &description, &primaryKey); err != nil {
+				result.Error = fmt.Sprintf("Failed to scan column metadata: %v", err)
+				return result, nil
+			}
+
+			col.Nullable = nullable == "TRUE"
+			col.PrimaryKey = primaryKey == "TRUE"
+			if defaultValue.Valid {
+				col.Default = &defaultValue.String
+			}
+			col.Comment = description.String
+			sapColumnSize(&col, length, scale)
+
+			columns = append(columns, col)
+		}
+		if err := rows.Err(); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Error = fmt.Sprintf("Error iterating rows: %v", err)
+			return result, nil
+		}
+
+		if len(columns) == 0 {
+			if schema == "" {
+				result.Error = fmt.Sprintf("Table or view %s not found in current schema", table)
+			} else {
+				result.Error = fmt.Sprintf("Table or view %s.%s not found", schema, table)
+			}
+			return result, nil
+		}
+
+		result.Success = true
+		result.Columns = columns
+
+		return result, nil
+	})
+}
+
+// sapColumnSize maps the LENGTH and SCALE catalog columns onto length for
+// character and binary types and precision/scale for decimals
+func sapColumnSize(col *models.ColumnInfo, length, scale sql.NullInt64) {
+	if !length.Valid {
+		return
+	}
+	size := int(length.Int64)
+
+	switch col.Type {
+	case "DECIMAL", "SMALLDECIMAL":
+		col.Precision = &size
+		// A NULL scale means a floating-point decimal
+		if scale.Valid {
+			s := int(scale.Int64)
+			col.Scale = &s
+		}
+	case "CHAR", "NCHAR", "VARCHAR", "NVARCHAR", "ALPHANUM", "SHORTTEXT",
+		"BINARY", "VARBINARY":
+		col.Length = &size
+	}
+}
@@ -0,0 +1,326 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"nexus-query-agent/internal/metrics"
+	"nexus-query-agent/internal/models"
+)
+
+// sapProcedureParamsQuery returns the procedure's result set count with one row
+// per parameter in position order, or with NULLs for a procedure without
+// parameters
+const sapProcedureParamsQuery = `
+	SELECT p.RESULT_SET_COUNT, pp.PARAMETER_NAME, pp.PARAMETER_TYPE, pp.DATA_TYPE_NAME
+	FROM SYS.PROCEDURES p
+	LEFT JOIN SYS.PROCEDURE_PARAMETERS pp
+		ON pp.SCHEMA_NAME = p.SCHEMA_NAME AND pp.PROCEDURE_NAME = p.PROCEDURE_NAME
+	WHERE p.SCHEMA_NAME = %s AND p.PROCEDURE_NAME = ?
+	ORDER BY pp.POSITION`
+
+// sapProcParam is a procedure parameter from SYS.PROCEDURE_PARAMETERS
+type sapProcParam struct {
+	name     string
+	mode     string // IN, OUT or INOUT
+	dataType string // DATA_TYPE_NAME, e.g. NVARCHAR or TABLE_TYPE
+	table    bool
+}
+
+// sapOutParam is the scan target of a scalar OUT or INOUT parameter
+type sapOutParam struct {
+	name     string
+	dataType string
+	dest     *any
+}
+
+// ExecuteCall calls a stored procedure. go-hdb only hands out the result sets
+// of a prepared call that are declared as table OUT parameters, and it fails
+// if asked for more than the procedure returns, so the call is built from the
+// procedure's catalog entry. Calls without parameters run unprepared and also
+// return the result sets of plain SELECTs in the procedure body; for calls with
+// parameters those are counted in OmittedResultSets instead.
+func (e *SapExecutor) ExecuteCall(ctx context.Context, ds *models.DatasourceInfo, call *ProcedureCall) (*models.QueryResult, error) {
+	startTime := time.Now()
+	defer metrics.ObserveDatasourceQuery(ds, "call", startTime)
+
+	result, err := e.call(ctx, ds, call)
+	if result != nil {
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+	}
+	return result, err
+}
+
+// call validates and executes a procedure call for ExecuteCall
+func (e *SapExecutor) call(ctx context.Context, ds *models.DatasourceInfo, call *ProcedureCall) (*models.QueryResult, error) {
+	result := &models.QueryResult{QueryType: "call"}
+
+	if err := checkIdent("procedure", call.Procedure); err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	if call.Schema != "" {
+		if err := checkIdent("schema", call.Schema); err != nil {
+			result.Error = err.Error()
+			return result, nil
+		}
+	}
+
+	return withDB(ctx, e.connect, ds, "call", func(db *sql.DB) (*models.QueryResult, error) {
+		params, resultSets, err := sapProcedureParams(ctx, db, call.Schema, call.Procedure)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Error = err.Error()
+			return result, nil
+		}
+
+		// Prepared statements and their result sets are bound to one connection
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Error = fmt.Sprintf("Connection failed: %v", err)
+			return result, nil
+		}
+		defer conn.Close()
+
+		d := sapDialect(e.limits.MaxLOBBytes)
+		name := d.qualifiedTable(call.Schema, call.Procedure)
+
+		if len(params) == 0 {
+			if len(call.Args) > 0 {
+				result.Error = fmt.Sprintf("Procedure %s has no parameters", call.Procedure)
+				return result, nil
+			}
+			if err := e.callDirect(ctx, conn, d, name, result); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				result.Error = err.Error()
+				return result, nil
+			}
+			result.Success = true
+			return result, nil
+		}
+
+		query, args, outs, tables, err := sapCallArgs(d, name, params, call.Args)
+		if err != nil {
+			result.Error = err.Error()
+			return result, nil
+		}
+
+		stmt, err := conn.PrepareContext(ctx, query)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Error = fmt.Sprintf("Failed to prepare call: %v", err)
+			return result, nil
+		}
+		// Table results stay readable until the statement is closed
+		defer stmt.Close()
+
+		res, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.Error = fmt.Sprintf("Call failed: %v", err)
+			return result, nil
+		}
+		if affected, err := res.RowsAffected(); err == nil {
+			result.AffectedRows = affected
+		}
+
+		for _, rows := range tables {
+			set, err := e.readResultSet(ctx, rows, d)
+			rows.Close()
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				result.Error = err.Error()
+				return result, nil
+			}
+			result.ResultSets = append(result.ResultSets, *set)
+		}
+		// The catalog counts table OUT parameters and SELECTs in the body alike;
+		// go-hdb can't read the latter from a prepared call
+		if omitted := resultSets - len(tables); omitted > 0 {
+			result.OmittedResultSets = omitted
+		}
+
+		if len(outs) > 0 {
+			result.OutParams = make(map[string]any, len(outs))
+			for _, out := range outs {
+				if *out.dest == nil {
+					result.OutParams[out.name] = nil
+					continue
+				}
+				v, err := sapTypedValue(out.dataType, *out.dest, e.limits.MaxLOBBytes)
+				if err != nil {
+					result.Error = fmt.Sprintf("Failed to convert parameter %s: %v", out.name, err)
+					return result, nil
+				}
+				result.OutParams[out.name] = v
+			}
+		}
+
+		result.Success = true
+		return result, nil
+	})
+}
+
+// sapProcedureParams looks up the parameters and result set count of a procedure
+func sapProcedureParams(ctx context.Context, db *sql.DB, schema, procedure string) ([]sapProcParam, int, error) {
+	filter, args := sapSchemaFilter(schema)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(sapProcedureParamsQuery, filter), append(args, procedure)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Failed to look up procedure: %v", err)
+	}
+	defer rows.Close()
+
+	found := false
+	var (
+		params     []sapProcParam
+		resultSets sql.NullInt64
+	)
+	for rows.Next() {
+		var name, mode, dataType sql.NullString
+		if err := rows.Scan(&resultSets, &name, &mode, &dataType); err != nil {
+			return nil, 0, fmt.Errorf("Failed to scan procedure parameters: %v", err)
+		}
+		found = true
+		if name.Valid {
+			params = append(params, sapProcParam{
+				name:     name.String,
+				mode:     mode.String,
+				dataType: dataType.String,
+				table:    dataType.String == "TABLE_TYPE",
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("Failed to look up procedure: %v", err)
+	}
+
+	if !found {
+		if schema == "" {
+			return nil, 0, fmt.Errorf("Procedure %s not found in current schema", procedure)
+		}
+		return nil, 0, fmt.Errorf("Procedure %s.%s not found", schema, procedure)
+	}
+	return params, int(resultSets.Int64), nil
+}
+
+// sapCallArgs builds the CALL statement with named parameters and its
+// arguments. Table OUT parameters are passed last, as go-hdb expects.
+func sapCallArgs(d *dialect, name string, params []sapProcParam, values map[string]any) (string, []any, []sapOutParam, []*sql.Rows, error) {
+	var (
+		placeholders []string
+		args         []any
+		outs         []sapOutParam
+		tables       []*sql.Rows
+	)
+
+	known := make(map[string]bool, len(params))
+	for _, p := range params {
+		known[p.name] = true
+		value, given := values[p.name]
+
+		switch {
+		case p.table && p.mode == "OUT":
+			tables = append(tables, new(sql.Rows))
+		case p.table:
+			if given {
+				return "", nil, nil, nil, fmt.Errorf("table parameter %s is not supported", p.name)
+			}
+			continue
+		case p.mode == "OUT":
+			if given {
+				return "", nil, nil, nil, fmt.Errorf("parameter %s is an OUT parameter", p.name)
+			}
+			dest := new(any)
+			outs = append(outs, sapOutParam{name: p.name, dataType: p.dataType, dest: dest})
+			args = append(args, sql.Named(p.name, sql.Out{Dest: dest}))
+		case p.mode == "INOUT":
+			dest := new(any)
+			*dest = bindParams([]any{value})[0]
+			outs = append(outs, sapOutParam{name: p.name, dataType: p.dataType, dest: dest})
+			args = append(args, sql.Named(p.name, sql.Out{Dest: dest, In: true}))
+		default:
+			if !given {
+				// Left to the parameter's default
+				continue
+			}
+			args = append(args, sql.Named(p.name, bindParams([]any{value})[0]))
+		}
+		placeholders = append(placeholders, d.quoteIdent(p.name)+" => ?")
+	}
+
+	for arg := range values {
+		if !known[arg] {
+			return "", nil, nil, nil, fmt.Errorf("procedure has no parameter %s", arg)
+		}
+	}
+
+	for _, rows := range tables {
+		args = append(args, sql.Out{Dest: rows})
+	}
+
+	query := fmt.Sprintf("CALL %s(%s)", name, strings.Join(placeholders, ", "))
+	return query, args, outs, tables, nil
+}
+
+// callDirect runs a call without parameters unprepared, which returns every
+// result set of the procedure
+func (e *SapExecutor) callDirect(ctx context.Context, conn *sql.Conn, d *dialect, name string, result *models.QueryResult) error {
+	rows, err := conn.QueryContext(ctx, "CALL "+name)
+	if err != nil {
+		return fmt.Errorf("Call failed: %v", err)
+	}
+	defer rows.Close()
+
+	for {
+		set, err := e.readResultSet(ctx, rows, d)
+		if err != nil {
+			return err
+		}
+		// A procedure without result sets yields one without columns
+		if len(set.Columns) > 0 {
+			result.ResultSets = append(result.ResultSets, *set)
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("Failed to read result sets: %v", err)
+	}
+	return nil
+}
+
+// readResultSet collects up to limits.max_rows rows of the current result set
+func (e *SapExecutor) readResultSet(ctx context.Context, rows *sql.Rows, d *dialect) (*models.ResultSet, error) {
+	collector := &rowCollector{}
+	pw := &pageWriter{RowWriter: collector, limit: e.limits.MaxRows}
+	if _, err := scanRows(ctx, rows, d, pw); err != nil {
+		return nil, err
+	}
+
+	set := &models.ResultSet{
+		Columns:   collector.columns,
+		Data:      collector.data,
+		Truncated: pw.more,
+	}
+	if set.Data == nil {
+		set.Data = []map[string]any{}
+	}
+	return set, nil
+}
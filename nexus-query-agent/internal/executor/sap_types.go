@@ -0,0 +1,188 @@
+package executor
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"github.com/SAP/go-hdb/driver"
+)
+
+// SAP HANA column encodings reported in ColumnInfo.Encoding
+const (
+	encDecimal   = "decimal"    // json.Number with the exact decimal digits
+	encBase64    = "base64"     // Binary values
+	encDate      = "date"       // 2006-01-02
+	encTime      = "time"       // 15:04:05
+	encTimestamp = "timestamp"  // 2006-01-02T15:04:05.9999999, no zone
+	encLOB       = "lob"        // Character LOB as text, size-limited
+	encBase64LOB = "base64_lob" // Binary LOB as base64, size-limited
+	encWKT       = "wkt"        // Well-known text; hex WKB if undecodable
+)
+
+// sapEncoding returns the encoding of a column by its HANA type name
+func sapEncoding(typeName string) string {
+	switch typeName {
+	case "DECIMAL", "SMALLDECIMAL", "FIXED8", "FIXED12", "FIXED16":
+		return encDecimal
+	case "BINARY", "VARBINARY", "BSTRING":
+		return encBase64
+	case "DATE", "DAYDATE":
+		return encDate
+	case "TIME", "SECONDTIME":
+		return encTime
+	case "TIMESTAMP", "LONGDATE", "SECONDDATE":
+		return encTimestamp
+	case "CLOB", "NCLOB", "TEXT", "BINTEXT", "LOCATOR", "NLOCATOR":
+		return encLOB
+	case "BLOB":
+		return encBase64LOB
+	case "STGEOMETRY", "STPOINT":
+		return encWKT
+	}
+	return ""
+}
+
+// sapDialect returns the dialect for SAP HANA; LOBs are read up to maxLOBBytes.
+// SAP HANA supports LIMIT/OFFSET, and bulk inserts and upserts via go-hdb's
+// extended argument lists.
+func sapDialect(maxLOBBytes int) *dialect {
+	return &dialect{
+		convert: func(ct *sql.ColumnType, val any) (any, error) {
+			return sapValue(ct, val, maxLOBBytes)
+		},
+		encoding: func(ct *sql.ColumnType) string {
+			return sapEncoding(ct.DatabaseTypeName())
+		},
+		arrayBind: true,
+		upsert:    sapUpsert,
+	}
+}
+
+// sapValue converts go-hdb driver values into JSON values matching sapEncoding
+func sapValue(ct *sql.ColumnType, val any, maxLOBBytes int) (any, error) {
+	return sapTypedValue(ct.DatabaseTypeName(), val, maxLOBBytes)
+}
+
+// sapTypedValue converts a value of the HANA type typeName, from a column or
+// a procedure parameter, like sapValue
+func sapTypedValue(typeName string, val any, maxLOBBytes int) (any, error) {
+	switch enc := sapEncoding(typeName); enc {
+	case encDecimal:
+		if r, ok := val.(*big.Rat); ok {
+			return json.Number(decimalString(r)), nil
+		}
+	case encBase64:
+		if b, ok := val.([]byte); ok {
+			return base64.StdEncoding.EncodeToString(b), nil
+		}
+	case encDate:
+		if t, ok := val.(time.Time); ok {
+			return t.Format(time.DateOnly), nil
+		}
+	case encTime:
+		if t, ok := val.(time.Time); ok {
+			return t.Format(time.TimeOnly), nil
+		}
+	case encTimestamp:
+		// HANA timestamps carry no zone; don't claim UTC
+		if t, ok := val.(time.Time); ok {
+			return t.Format("2006-01-02T15:04:05.9999999"), nil
+		}
+	case encLOB, encBase64LOB:
+		return readLOB(val, maxLOBBytes, enc == encBase64LOB)
+	case encWKT:
+		if s, ok := val.(string); ok {
+			if wkb, err := hex.DecodeString(s); err == nil {
+				if wkt, err := wkbToWKT(wkb); err == nil {
+					return wkt, nil
+				}
+			}
+			return s, nil
+		}
+	}
+
+	return defaultConverter(nil, val)
+}
+
+// decimalString formats r, which go-hdb builds from a decimal mantissa and
+// exponent, with exactly as many fraction digits as it has
+func decimalString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// The denominator is 2^a * 5^b; max(a, b) fraction digits are exact
+	den := new(big.Int).Set(r.Denom())
+	var twos, fives int
+	two, five, mod := big.NewInt(2), big.NewInt(5), new(big.Int)
+	for mod.Mod(den, two).Sign() == 0 {
+		den.Quo(den, two)
+		twos++
+	}
+	for mod.Mod(den, five).Sign() == 0 {
+		den.Quo(den, five)
+		fives++
+	}
+	return r.FloatString(max(twos, fives))
+}
+
+// errLOBTruncated stops reading a LOB once the limit is reached
+var errLOBTruncated = errors.New("lob truncated")
+
+// lobBuffer collects LOB content up to max bytes
+type lobBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *lobBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:room])
+		b.truncated = true
+		return room, errLOBTruncated
+	}
+	return b.buf.Write(p)
+}
+
+// truncatedLOB is sent instead of a LOB larger than limits.max_lob_bytes
+type truncatedLOB struct {
+	Truncated bool   `json:"truncated"`
+	Data      string `json:"data"`
+}
+
+// readLOB reads a LOB value as text, or base64 for binary LOBs
+func readLOB(val any, maxBytes int, binary bool) (any, error) {
+	b := &lobBuffer{max: maxBytes}
+	if err := driver.ScanLobWriter(val, b); err != nil && !b.truncated {
+		return nil, fmt.Errorf("reading lob: %w", err)
+	}
+
+	data := b.buf.Bytes()
+	var s string
+	if binary {
+		s = base64.StdEncoding.EncodeToString(data)
+	} else {
+		// Don't split a UTF-8 sequence at the cut
+		for i := 0; b.truncated && i < utf8.UTFMax-1 && len(data) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(data); r != utf8.RuneError || size != 1 {
+				break
+			}
+			data = data[:len(data)-1]
+		}
+		s = string(data)
+	}
+
+	if b.truncated {
+		return truncatedLOB{Truncated: true, Data: s}, nil
+	}
+	return s, nil
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestDecimalString(t *testing.T) {
+	tests := []struct {
+		in   string // Parsed into a *big.Rat as go-hdb builds it from mantissa and exponent
+		want string
+	}{
+		{"0", "0"},
+		{"42", "42"},
+		{"-42", "-42"},
+		{"1e30", "1000000000000000000000000000000"},
+		{"0.5", "0.5"},
+		{"0.125", "0.125"},
+		{"0.001", "0.001"},
+		{"-0.05", "-0.05"},
+		{"12345.6789", "12345.6789"},
+		{"0.0000000000000000000000000001", "0.0000000000000000000000000001"},
+		{"99999999999999999999999999999999999.9", "99999999999999999999999999999999999.9"},
+		{"314159e-5", "3.14159"},
+		// big.Rat normalizes 1.50 to 3/2; the digits that remain are exact
+		{"1.50", "1.5"},
+	}
+
+	for _, tt := range tests {
+		r, ok := new(big.Rat).SetString(tt.in)
+		if !ok {
+			t.Fatalf("invalid test decimal %q", tt.in)
+		}
+		if got := decimalString(r); got != tt.want {
+			t.Errorf("decimalString(%s) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSAPDecimalValue(t *testing.T) {
+	r, _ := new(big.Rat).SetString("-123.045")
+	got, err := sapTypedValue("DECIMAL", r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != json.Number("-123.045") {
+		t.Errorf("sapTypedValue(DECIMAL) = %#v, want json.Number(-123.045)", got)
+	}
+}
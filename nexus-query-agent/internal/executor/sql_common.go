@@ -0,0 +1,501 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"nexus-query-agent/internal/logging"
+	"nexus-query-agent/internal/models"
+)
+
+// valueConverter turns a raw driver value into a JSON-friendly value. An
+// error fails the query, so converters fall back to a raw form where they can.
+type valueConverter func(ct *sql.ColumnType, val any) (any, error)
+
+// dialect captures the per-database differences of the shared SQL helpers
+type dialect struct {
+	// convert maps driver values to JSON values; nil converts []byte to string
+	convert valueConverter
+	// encoding names the JSON representation of a column's values for
+	// ColumnInfo.Encoding; nil leaves it unset
+	encoding func(ct *sql.ColumnType) string
+	// typeName rewrites the driver's type name for ColumnInfo; nil keeps it
+	typeName func(name string) string
+	// quote quotes an identifier; nil uses ANSI double quotes
+	quote func(name string) string
+	// placeholder returns the bind variable for the n-th (1-based) param; nil uses "?"
+	placeholder func(n int) string
+	// arrayBind means the driver executes a single-row statement once per row
+	// of an extended argument list (go-hdb bulk insert)
+	arrayBind bool
+	// upsert builds an upsert of rows rows; nil means upserts are unsupported
+	upsert func(d *dialect, b *BulkInsert, rows int) (string, error)
+	// upsertCounts means upsert statements return an "inserted" boolean per
+	// written row, so inserted and updated rows can be told apart
+	upsertCounts bool
+	// syntax selects the lexical rules of the SQL guard
+	syntax sqlSyntax
+}
+
+// queryer is a *sql.DB or *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// bindVar returns the bind variable for the n-th (1-based) param
+func (d *dialect) bindVar(n int) string {
+	if d.placeholder != nil {
+		return d.placeholder(n)
+	}
+	return "?"
+}
+
+// quoteIdent quotes a (validated) identifier for the dialect
+func (d *dialect) quoteIdent(name string) string {
+	if d.quote != nil {
+		return d.quote(name)
+	}
+	return `"` + name + `"`
+}
+
+// defaultConverter converts []byte to string and passes everything else through
+func defaultConverter(_ *sql.ColumnType, val any) (any, error) {
+	if b, ok := val.([]byte); ok {
+		return string(b), nil
+	}
+	return val, nil
+}
+
+// RowWriter receives the rows of a streamed SELECT
+type RowWriter interface {
+	// WriteColumns is called once before the first row
+	WriteColumns(columns []models.ColumnInfo) error
+	WriteRow(row map[string]any) error
+}
+
+// normalizePage applies row limits and defaults to the requested page
+func normalizePage(p Paging, maxRows int) Paging {
+	if p.Limit <= 0 || p.Limit > maxRows {
+		p.Limit = maxRows
+	}
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	return p
+}
+
+// bindParams prepares JSON-decoded parameters for the driver. encoding/json
+// decodes every number as float64, so whole numbers are bound as int64.
+func bindParams(params []any) []any {
+	args := make([]any, len(params))
+	for i, p := range params {
+		if f, ok := p.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			args[i] = int64(f)
+			continue
+		}
+		args[i] = p
+	}
+	return args
+}
+
+// paginate wraps query with LIMIT/OFFSET pagination
+func paginate(query string, limit, offset int) string {
+	return fmt.Sprintf(`
+		SELECT * FROM (%s) AS subquery
+		LIMIT %d OFFSET %d
+	`, query, limit, offset)
+}
+
+// selectPage wraps query with LIMIT/OFFSET or keyset pagination, runs it
+// together with the matching COUNT query (as p.Count allows) and builds the
+// select result. params are bound to both queries. Database errors are
+// reported in the result; an error is only returned when ctx is done.
+func selectPage(ctx context.Context, db *sql.DB, d *dialect, ds *models.DatasourceInfo, query string, params []any, p Paging) (*models.QueryResult, error) {
+	collect := &rowCollector{data: make([]map[string]any, 0)}
+
+	result, err := streamPage(ctx, db, d, ds, query, params, p, collect)
+	if err != nil || !result.Success {
+		return result, err
+	}
+
+	result.Data = collect.data
+	result.Columns = collect.columns
+	return result, nil
+}
+
+// streamPage is like selectPage but hands columns and rows to w as they are
+// read instead of collecting them. Errors from w are returned as-is.
+func streamPage(ctx context.Context, db *sql.DB, d *dialect, ds *models.DatasourceInfo, query string, params []any, p Paging, w RowWriter) (*models.QueryResult, error) {
+	if err := guardQuery(d, "select", query); err != nil {
+		return &models.QueryResult{
+			Success: false,
+			Error:   fmt.Sprintf("Query rejected: %v", err),
+		}, nil
+	}
+
+	// Page and count queries share the read-only transaction
+	var q queryer = db
+	if p.ReadOnly {
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return &models.QueryResult{
+				Success: false,
+				Error:   fmt.Sprintf("Failed to begin read-only transaction: %v", err),
+			}, nil
+		}
+		defer tx.Rollback()
+		q = tx
+	}
+
+	args := bindParams(params)
+
+	// One row past the page tells whether another page follows
+	offset := (p.Page - 1) * p.Limit
+	pageQuery, pageArgs := paginate(query, p.Limit+1, offset), args
+
+	// Keyset pagination filters and orders by the key instead of skipping rows
+	var keyset *keysetWriter
+	if p.Keyset != nil {
+		var err error
+		pageQuery, pageArgs, err = keysetQuery(d, query, args, p.Keyset, p.Limit+1)
+		if err != nil {
+			return &models.QueryResult{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
+		}
+		keyset = &keysetWriter{RowWriter: w, keyset: p.Keyset}
+		w = keyset
+
+		offset = 0
+		if p.Keyset.Cursor != "" {
+			offset = -1 // Position unknown
+		}
+	}
+
+	pw := &pageWriter{RowWriter: w, limit: p.Limit}
+
+	// Execute query
+	rows, err := q.QueryContext(ctx, pageQuery, pageArgs...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return &models.QueryResult{
+			Success: false,
+			Error:   fmt.Sprintf("Query failed: %v", err),
+		}, nil
+	}
+	defer rows.Close()
+
+	if _, err := scanRows(ctx, rows, d, pw); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var scanErr *scanError
+		if errors.As(err, &scanErr) {
+			return &models.QueryResult{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
+		}
+		return nil, err
+	}
+	// Release the connection before counting
+	rows.Close()
+
+	pagination := &models.Pagination{
+		Page:    p.Page,
+		Limit:   p.Limit,
+		HasMore: pw.more,
+	}
+
+	total, kind, err := totalRows(ctx, q, ds, query, args, p, offset, pw)
+	if err != nil {
+		return nil, err
+	}
+	pagination.TotalKind = kind
+	if kind != models.TotalUnknown {
+		pagination.TotalRows = total
+		pagination.TotalPages = (total + p.Limit - 1) / p.Limit
+	}
+
+	// Hand out a cursor for the rows after this page
+	if keyset != nil && pw.more {
+		cursor, err := keyset.nextCursor()
+		if err != nil {
+			return &models.QueryResult{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
+		}
+		pagination.NextCursor = cursor
+	}
+
+	return &models.QueryResult{
+		Success:    true,
+		QueryType:  "select",
+		Pagination: pagination,
+	}, nil
+}
+
+// totalRows returns the total row count of query as p.Count allows. offset is
+// the page's position in the result, or -1 if unknown. An error is only
+// returned when ctx is done.
+func totalRows(ctx context.Context, q queryer, ds *models.DatasourceInfo, query string, args []any, p Paging, offset int, pw *pageWriter) (int, string, error) {
+	// The last page tells the total for free, unless it is empty: a page past
+	// the end says nothing about how many rows came before it
+	if offset >= 0 && !pw.more && (pw.written > 0 || offset == 0) {
+		return offset + pw.written, models.TotalExact, nil
+	}
+
+	var key string
+	switch p.Count {
+	case CountNone:
+		return 0, models.TotalUnknown, nil
+	case CountFirstPage:
+		if offset != 0 {
+			return 0, models.TotalUnknown, nil
+		}
+	case CountCached:
+		key = countKey(ds, query, args)
+		if total, ok := counts.get(key); ok {
+			return total, models.TotalEstimated, nil
+		}
+	}
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS subquery", query)
+	if err := q.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		if ctx.Err() != nil {
+			return 0, "", ctx.Err()
+		}
+		logging.FromContext(ctx).Warn("Failed to get total count", "error", err)
+		return 0, models.TotalUnknown, nil
+	}
+
+	if key != "" {
+		counts.put(key, total, p.CountTTL)
+	}
+	return total, models.TotalExact, nil
+}
+
+// scanError is a failure reading the result set, as opposed to a RowWriter failure
+type scanError struct {
+	msg string
+}
+
+func (e *scanError) Error() string { return e.msg }
+
+// scanRows reads column metadata and all rows from a result set, passing them
+// to w, and returns the number of rows read
+func scanRows(ctx context.Context, rows *sql.Rows, d *dialect, w RowWriter) (int, error) {
+	convert := d.convert
+	if convert == nil {
+		convert = defaultConverter
+	}
+
+	// Get column info
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, &scanError{fmt.Sprintf("Failed to get columns: %v", err)}
+	}
+
+	columns := make([]models.ColumnInfo, len(columnTypes))
+	for i, ct := range columnTypes {
+		nullable, _ := ct.Nullable()
+		typeName := ct.DatabaseTypeName()
+		if d.typeName != nil {
+			typeName = d.typeName(typeName)
+		}
+		columns[i] = models.ColumnInfo{
+			Name:     ct.Name(),
+			Type:     typeName,
+			Nullable: nullable,
+		}
+		if d.encoding != nil {
+			columns[i].Encoding = d.encoding(ct)
+		}
+	}
+	if err := w.WriteColumns(columns); err != nil {
+		return 0, err
+	}
+
+	// Scan rows
+	count := 0
+	for rows.Next() {
+		values := make([]any, len(columnTypes))
+		valuePtrs := make([]any, len(columnTypes))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			logging.FromContext(ctx).Error("Failed to scan row", "error", err)
+			continue
+		}
+
+		row := make(map[string]any, len(columnTypes))
+		for i, ct := range columnTypes {
+			if values[i] == nil {
+				row[ct.Name()] = nil
+				continue
+			}
+			v, err := convert(ct, values[i])
+			if err != nil {
+				return count, &scanError{fmt.Sprintf("Failed to convert column %s: %v", ct.Name(), err)}
+			}
+			row[ct.Name()] = v
+		}
+		if err := w.WriteRow(row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, &scanError{fmt.Sprintf("Failed to read rows: %v", err)}
+	}
+
+	return count, nil
+}
+
+// rowCollector is a RowWriter that keeps rows in memory
+type rowCollector struct {
+	columns []models.ColumnInfo
+	data    []map[string]any
+}
+
+func (c *rowCollector) WriteColumns(columns []models.ColumnInfo) error {
+	c.columns = columns
+	return nil
+}
+
+func (c *rowCollector) WriteRow(row map[string]any) error {
+	c.data = append(c.data, row)
+	return nil
+}
+
+// execDML executes a single INSERT, UPDATE or DELETE in its own transaction.
+// Like selectPage, it only returns an error when ctx is done.
+func execDML(ctx context.Context, db *sql.DB, d *dialect, queryType, query string, params []any, startTime time.Time) (*models.QueryResult, error) {
+	logger := logging.FromContext(ctx)
+
+	if err := guardQuery(d, queryType, query); err != nil {
+		return &models.QueryResult{
+			Success:         false,
+			QueryType:       queryType,
+			Error:           fmt.Sprintf("Query rejected: %v", err),
+			ExecutionTimeMs: time.Since(startTime).Milliseconds(),
+		}, nil
+	}
+
+	// Start transaction
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return &models.QueryResult{
+			Success:   false,
+			QueryType: queryType,
+			Error:     fmt.Sprintf("Failed to begin transaction: %v", err),
+		}, nil
+	}
+
+	// Defer rollback in case of panic
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			logger.Error("Panic during DML, rolled back", "panic", r)
+		}
+	}()
+
+	logger.Debug("Executing DML", "params", len(params))
+
+	// Execute DML query
+	var result sql.Result
+	if len(params) > 0 {
+		result, err = tx.ExecContext(ctx, query, bindParams(params)...)
+	} else {
+		result, err = tx.ExecContext(ctx, query)
+	}
+
+	if err != nil {
+		// Rollback on error
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Error("Rollback failed", "error", rollbackErr)
+		}
+		logger.Error("DML failed, rolled back", "error", err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return &models.QueryResult{
+			Success:         false,
+			QueryType:       queryType,
+			Error:           fmt.Sprintf("%s failed: %v (transaction rolled back)", queryType, err),
+			ExecutionTimeMs: time.Since(startTime).Milliseconds(),
+		}, nil
+	}
+
+	// Get affected rows
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		logger.Warn("Could not get affected rows", "error", err)
+		affectedRows = 0
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return &models.QueryResult{
+			Success:         false,
+			QueryType:       queryType,
+			Error:           fmt.Sprintf("Failed to commit transaction: %v", err),
+			ExecutionTimeMs: time.Since(startTime).Milliseconds(),
+		}, nil
+	}
+
+	executionTime := time.Since(startTime).Milliseconds()
+	logger.Debug("DML committed", "affected_rows", affectedRows, "duration_ms", executionTime)
+
+	return &models.QueryResult{
+		Success:         true,
+		QueryType:       queryType,
+		AffectedRows:    affectedRows,
+		ExecutionTimeMs: executionTime,
+	}, nil
+}
+
+// connectFunc returns a pooled handle for a datasource and its release func
+type connectFunc func(ctx context.Context, ds *models.DatasourceInfo) (*sql.DB, func(), error)
+
+// withDB gets a pooled handle for ds, runs fn with it and releases it again.
+// A connection failure is reported as a failed result of queryType; like
+// selectPage, an error is only returned when ctx is done.
+func withDB(ctx context.Context, connect connectFunc, ds *models.DatasourceInfo, queryType string, fn func(db *sql.DB) (*models.QueryResult, error)) (*models.QueryResult, error) {
+	db, release, err := connect(ctx, ds)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return &models.QueryResult{
+			Success:   false,
+			QueryType: queryType,
+			Error:     fmt.Sprintf("Connection failed: %v", err),
+		}, nil
+	}
+	defer release()
+
+	return fn(db)
+}
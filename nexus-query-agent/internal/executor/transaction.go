@@ -0,0 +1,89 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"nexus-query-agent/internal/logging"
+	"nexus-query-agent/internal/models"
+)
+
+// execTransaction runs statements in order in a single transaction and
+// commits only if all succeed. On failure the result names the statement
+// that caused the rollback. Like execDML, it only returns an error when ctx
+// is done.
+func execTransaction(ctx context.Context, db *sql.DB, d *dialect, statements []models.Statement, startTime time.Time) (*models.QueryResult, error) {
+	logger := logging.FromContext(ctx)
+
+	result := &models.QueryResult{QueryType: "transaction"}
+	fail := func(index int, msg string) *models.QueryResult {
+		if index >= 0 {
+			result.FailedStatement = &index
+		}
+		result.Error = msg
+		result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+		return result
+	}
+
+	for i, stmt := range statements {
+		if err := guardQuery(d, "transaction", stmt.Query); err != nil {
+			return fail(i, fmt.Sprintf("Statement %d rejected: %v", i, err)), nil
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return fail(-1, fmt.Sprintf("Failed to begin transaction: %v", err)), nil
+	}
+
+	// Defer rollback in case of panic
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			logger.Error("Panic during transaction, rolled back", "panic", r)
+		}
+	}()
+
+	logger.Debug("Executing transaction", "statements", len(statements))
+
+	result.Statements = make([]models.StatementResult, 0, len(statements))
+	for i, stmt := range statements {
+		res, err := tx.ExecContext(ctx, stmt.Query, bindParams(stmt.Params)...)
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				logger.Error("Rollback failed", "error", rollbackErr)
+			}
+			logger.Error("Transaction failed, rolled back", "statement", i, "error", err)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return fail(i, fmt.Sprintf("Statement %d failed: %v (transaction rolled back)", i, err)), nil
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			logger.Warn("Could not get affected rows", "statement", i, "error", err)
+			affected = 0
+		}
+		result.Statements = append(result.Statements, models.StatementResult{Index: i, AffectedRows: affected})
+		result.AffectedRows += affected
+	}
+
+	if err := tx.Commit(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return fail(-1, fmt.Sprintf("Failed to commit transaction: %v", err)), nil
+	}
+
+	result.Success = true
+	result.ExecutionTimeMs = time.Since(startTime).Milliseconds()
+	logger.Debug("Transaction committed", "affected_rows", result.AffectedRows, "duration_ms", result.ExecutionTimeMs)
+
+	return result, nil
+}
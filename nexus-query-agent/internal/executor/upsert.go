@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// quoteColumns quotes validated column names for the dialect
+func (d *dialect) quoteColumns(cols []string) []string {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = d.quoteIdent(col)
+	}
+	return quoted
+}
+
+// updateColumns returns the columns an upsert overwrites on matching rows
+func (b *BulkInsert) updateColumns() []string {
+	var cols []string
+	for _, col := range b.Columns {
+		if !slices.Contains(b.Key, col) {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// sapUpsert uses UPSERT ... WITH PRIMARY KEY, or MERGE INTO on an explicit
+// key. Both take one row; go-hdb executes them once per row of the chunk.
+func sapUpsert(d *dialect, b *BulkInsert, _ int) (string, error) {
+	table := d.qualifiedTable(b.Schema, b.Table)
+	cols := d.quoteColumns(b.Columns)
+
+	if len(b.Key) == 0 {
+		vars := make([]string, len(cols))
+		for i := range vars {
+			vars[i] = d.bindVar(i + 1)
+		}
+		return fmt.Sprintf("UPSERT %s (%s) VALUES (%s) WITH PRIMARY KEY",
+			table, strings.Join(cols, ", "), strings.Join(vars, ", ")), nil
+	}
+
+	source := make([]string, len(cols))
+	values := make([]string, len(cols))
+	for i, col := range cols {
+		source[i] = d.bindVar(i+1) + " AS " + col
+		values[i] = `"source".` + col
+	}
+	on := make([]string, len(b.Key))
+	for i, col := range d.quoteColumns(b.Key) {
+		on[i] = `"target".` + col + ` = "source".` + col
+	}
+
+	var matched string
+	if update := d.quoteColumns(b.updateColumns()); len(update) > 0 {
+		set := make([]string, len(update))
+		for i, col := range update {
+			set[i] = `"target".` + col + ` = "source".` + col
+		}
+		matched = "WHEN MATCHED THEN UPDATE SET " + strings.Join(set, ", ")
+	}
+
+	return fmt.Sprintf(`MERGE INTO %s AS "target"
+		USING (SELECT %s FROM DUMMY) AS "source"
+		ON %s
+		%s
+		WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)`,
+		table, strings.Join(source, ", "), strings.Join(on, " AND "),
+		matched, strings.Join(cols, ", "), strings.Join(values, ", ")), nil
+}
+
+// mysqlUpsert uses INSERT ... AS new ON DUPLICATE KEY UPDATE, which matches
+// on any primary or unique key of the table; Key only keeps columns from being
+// updated. The row alias replaces VALUES(col), deprecated since MySQL 8.0.20.
+//
+// The affected rows are MySQL's raw count: 1 per inserted row, 2 per updated
+// row and 0 per row left unchanged. The split cannot be recovered from it, so
+// mysqlDialect leaves upsertCounts unset.
+func mysqlUpsert(d *dialect, b *BulkInsert, rows int) (string, error) {
+	var set []string
+	for _, col := range d.quoteColumns(b.updateColumns()) {
+		set = append(set, col+" = new."+col)
+	}
+	if len(set) == 0 {
+		// Nothing to update: leave matching rows as they are
+		col := d.quoteIdent(b.Columns[0])
+		set = append(set, col+" = "+col)
+	}
+	return d.insertStatement(b, rows) + " AS new ON DUPLICATE KEY UPDATE " + strings.Join(set, ", "), nil
+}
+
+// postgresUpsert uses INSERT ... ON CONFLICT, which needs the key columns.
+// xmax is zero only for freshly inserted row versions, so RETURNING tells
+// inserted and updated rows apart.
+func postgresUpsert(d *dialect, b *BulkInsert, rows int) (string, error) {
+	if len(b.Key) == 0 {
+		return "", errors.New("key is required for PostgreSQL upserts")
+	}
+
+	action := "DO NOTHING"
+	if update := d.quoteColumns(b.updateColumns()); len(update) > 0 {
+		set := make([]string, len(update))
+		for i, col := range update {
+			set[i] = col + " = EXCLUDED." + col
+		}
+		action = "DO UPDATE SET " + strings.Join(set, ", ")
+	}
+
+	return fmt.Sprintf("%s ON CONFLICT (%s) %s RETURNING (xmax = 0) AS inserted",
+		d.insertStatement(b, rows), strings.Join(d.quoteColumns(b.Key), ", "), action), nil
+}
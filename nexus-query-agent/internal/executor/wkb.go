@@ -0,0 +1,257 @@
+package executor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errInvalidWKB = errors.New("invalid WKB")
+
+// wkbToWKT converts (extended) well-known binary to well-known text. ISO
+// (type + 1000/2000/3000) and EWKB (flag bits) Z/M variants are supported;
+// an EWKB SRID is dropped.
+func wkbToWKT(b []byte) (string, error) {
+	r := &wkbReader{data: b}
+	var sb strings.Builder
+	if err := r.geometry(&sb); err != nil {
+		return "", err
+	}
+	if r.pos != len(r.data) {
+		return "", errInvalidWKB
+	}
+	return sb.String(), nil
+}
+
+// EWKB flag bits in the geometry type
+const (
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+var wkbTypeNames = map[uint32]string{
+	1: "POINT",
+	2: "LINESTRING",
+	3: "POLYGON",
+	4: "MULTIPOINT",
+	5: "MULTILINESTRING",
+	6: "MULTIPOLYGON",
+	7: "GEOMETRYCOLLECTION",
+	8: "CIRCULARSTRING",
+}
+
+type wkbReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, errInvalidWKB
+	}
+	v := r.order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+func (r *wkbReader) float64() (float64, error) {
+	if len(r.data)-r.pos < 8 {
+		return 0, errInvalidWKB
+	}
+	v := r.order.Uint64(r.data[r.pos:])
+	r.pos += 8
+	return math.Float64frombits(v), nil
+}
+
+// count reads an element count, rejecting counts the remaining bytes can't hold
+func (r *wkbReader) count(minSize int) (int, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if int(n) > (len(r.data)-r.pos)/minSize {
+		return 0, errInvalidWKB
+	}
+	return int(n), nil
+}
+
+// geometry reads one geometry including its byte order and type header
+func (r *wkbReader) geometry(sb *strings.Builder) error {
+	if r.pos >= len(r.data) {
+		return errInvalidWKB
+	}
+	switch r.data[r.pos] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return errInvalidWKB
+	}
+	r.pos++
+
+	typ, err := r.uint32()
+	if err != nil {
+		return err
+	}
+	hasZ, hasM := typ&ewkbZ != 0, typ&ewkbM != 0
+	if typ&ewkbSRID != 0 {
+		if _, err := r.uint32(); err != nil {
+			return err
+		}
+	}
+	typ &^= ewkbZ | ewkbM | ewkbSRID
+
+	switch typ / 1000 {
+	case 1:
+		hasZ = true
+	case 2:
+		hasM = true
+	case 3:
+		hasZ, hasM = true, true
+	}
+	base := typ % 1000
+
+	name, ok := wkbTypeNames[base]
+	if !ok {
+		return fmt.Errorf("unsupported WKB geometry type %d", typ)
+	}
+	sb.WriteString(name)
+	switch {
+	case hasZ && hasM:
+		sb.WriteString(" ZM")
+	case hasZ:
+		sb.WriteString(" Z")
+	case hasM:
+		sb.WriteString(" M")
+	}
+
+	dims := 2
+	if hasZ {
+		dims++
+	}
+	if hasM {
+		dims++
+	}
+
+	switch base {
+	case 1:
+		return r.point(sb, dims)
+	case 2, 8:
+		return r.points(sb, dims)
+	case 3:
+		return r.rings(sb, dims)
+	default:
+		// Collections: each member is a full geometry
+		n, err := r.count(9)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			sb.WriteString(" EMPTY")
+			return nil
+		}
+		sb.WriteString(" (")
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			var member strings.Builder
+			if err := r.geometry(&member); err != nil {
+				return err
+			}
+			s := member.String()
+			// MULTIPOINT ((1 2)), MULTIPOLYGON (((...))): members lose their tag
+			if base != 7 {
+				s = strings.TrimLeft(s[strings.IndexAny(s, " ("):], " ZM")
+			}
+			sb.WriteString(s)
+		}
+		sb.WriteString(")")
+		return nil
+	}
+}
+
+// coords writes one coordinate tuple
+func (r *wkbReader) coords(sb *strings.Builder, dims int) error {
+	for i := 0; i < dims; i++ {
+		f, err := r.float64()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+	}
+	return nil
+}
+
+// point writes a point body; WKB encodes POINT EMPTY as NaN coordinates
+func (r *wkbReader) point(sb *strings.Builder, dims int) error {
+	var body strings.Builder
+	if err := r.coords(&body, dims); err != nil {
+		return err
+	}
+	if body.String() == strings.TrimSpace(strings.Repeat("NaN ", dims)) {
+		sb.WriteString(" EMPTY")
+		return nil
+	}
+	sb.WriteString(" (")
+	sb.WriteString(body.String())
+	sb.WriteString(")")
+	return nil
+}
+
+// points writes a coordinate list
+func (r *wkbReader) points(sb *strings.Builder, dims int) error {
+	n, err := r.count(8 * dims)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		sb.WriteString(" EMPTY")
+		return nil
+	}
+	sb.WriteString(" (")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if err := r.coords(sb, dims); err != nil {
+			return err
+		}
+	}
+	sb.WriteString(")")
+	return nil
+}
+
+// rings writes a polygon's rings
+func (r *wkbReader) rings(sb *strings.Builder, dims int) error {
+	n, err := r.count(4)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		sb.WriteString(" EMPTY")
+		return nil
+	}
+	sb.WriteString(" (")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		var ring strings.Builder
+		if err := r.points(&ring, dims); err != nil {
+			return err
+		}
+		sb.WriteString(strings.TrimPrefix(ring.String(), " "))
+	}
+	sb.WriteString(")")
+	return nil
+}
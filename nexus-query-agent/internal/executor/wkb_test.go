@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// geom encodes a WKB geometry in order. body holds uint32 counts, float64
+// coordinates and []byte member geometries (each with its own byte order).
+func geom(order binary.AppendByteOrder, typ uint32, body ...any) []byte {
+	b := []byte{0}
+	if order == binary.LittleEndian {
+		b[0] = 1
+	}
+	b = order.AppendUint32(b, typ)
+	for _, v := range body {
+		switch v := v.(type) {
+		case uint32:
+			b = order.AppendUint32(b, v)
+		case int:
+			b = order.AppendUint32(b, uint32(v))
+		case float64:
+			b = order.AppendUint64(b, math.Float64bits(v))
+		case []byte:
+			b = append(b, v...)
+		default:
+			panic("geom: unsupported body value")
+		}
+	}
+	return b
+}
+
+func TestWKBToWKT(t *testing.T) {
+	orders := []struct {
+		name  string
+		order binary.AppendByteOrder
+	}{
+		{"big endian", binary.BigEndian},
+		{"little endian", binary.LittleEndian},
+	}
+
+	for _, o := range orders {
+		bo := o.order
+		ring := []any{4, 0.0, 0.0, 10.0, 0.0, 10.0, 10.0, 0.0, 0.0}
+		hole := []any{4, 2.0, 2.0, 3.0, 2.0, 3.0, 3.0, 2.0, 2.0}
+
+		tests := []struct {
+			name string
+			wkb  []byte
+			want string
+		}{
+			{"point", geom(bo, 1, 1.5, -2.25), "POINT (1.5 -2.25)"},
+			{"point empty", geom(bo, 1, math.NaN(), math.NaN()), "POINT EMPTY"},
+			{"point z iso", geom(bo, 1001, 1.0, 2.0, 3.0), "POINT Z (1 2 3)"},
+			{"point m iso", geom(bo, 2001, 1.0, 2.0, 4.0), "POINT M (1 2 4)"},
+			{"point zm iso", geom(bo, 3001, 1.0, 2.0, 3.0, 4.0), "POINT ZM (1 2 3 4)"},
+			{"point z ewkb", geom(bo, 1|ewkbZ, 1.0, 2.0, 3.0), "POINT Z (1 2 3)"},
+			{"point ewkb srid", geom(bo, 1|ewkbSRID, uint32(4326), 1.0, 2.0), "POINT (1 2)"},
+			{"linestring", geom(bo, 2, 2, 0.0, 0.0, 1e6, 0.125), "LINESTRING (0 0, 1000000 0.125)"},
+			{"linestring empty", geom(bo, 2, 0), "LINESTRING EMPTY"},
+			{"polygon", geom(bo, 3, append(append([]any{2}, ring...), hole...)...),
+				"POLYGON ((0 0, 10 0, 10 10, 0 0), (2 2, 3 2, 3 3, 2 2))"},
+			{"polygon empty", geom(bo, 3, 0), "POLYGON EMPTY"},
+			{"multipoint", geom(bo, 4, 2, geom(bo, 1, 1.0, 2.0), geom(bo, 1, 3.0, 4.0)), "MULTIPOINT ((1 2), (3 4))"},
+			{"multipoint z", geom(bo, 1004, 1, geom(bo, 1001, 1.0, 2.0, 3.0)), "MULTIPOINT Z ((1 2 3))"},
+			{"multilinestring", geom(bo, 5, 2, geom(bo, 2, 2, 0.0, 0.0, 1.0, 1.0), geom(bo, 2, 2, 2.0, 2.0, 3.0, 3.0)),
+				"MULTILINESTRING ((0 0, 1 1), (2 2, 3 3))"},
+			{"multipolygon", geom(bo, 6, 2, geom(bo, 3, append([]any{1}, ring...)...), geom(bo, 3, append([]any{1}, hole...)...)),
+				"MULTIPOLYGON (((0 0, 10 0, 10 10, 0 0)), ((2 2, 3 2, 3 3, 2 2)))"},
+			{"multipolygon empty", geom(bo, 6, 0), "MULTIPOLYGON EMPTY"},
+			{"geometrycollection", geom(bo, 7, 2, geom(bo, 1, 1.0, 2.0), geom(bo, 2, 2, 0.0, 0.0, 1.0, 1.0)),
+				"GEOMETRYCOLLECTION (POINT (1 2), LINESTRING (0 0, 1 1))"},
+			{"nested collection", geom(bo, 7, 1, geom(bo, 7, 1, geom(bo, 1, 1.0, 2.0))),
+				"GEOMETRYCOLLECTION (GEOMETRYCOLLECTION (POINT (1 2)))"},
+			{"mixed member byte order", geom(bo, 4, 2, geom(binary.BigEndian, 1, 1.0, 2.0), geom(binary.LittleEndian, 1, 3.0, 4.0)),
+				"MULTIPOINT ((1 2), (3 4))"},
+			{"circularstring", geom(bo, 8, 3, 0.0, 0.0, 1.0, 1.0, 2.0, 0.0), "CIRCULARSTRING (0 0, 1 1, 2 0)"},
+		}
+
+		for _, tt := range tests {
+			t.Run(o.name+"/"+tt.name, func(t *testing.T) {
+				got, err := wkbToWKT(tt.wkb)
+				if err != nil {
+					t.Fatalf("wkbToWKT(%x) error: %v", tt.wkb, err)
+				}
+				if got != tt.want {
+					t.Errorf("wkbToWKT(%x) = %q, want %q", tt.wkb, got, tt.want)
+				}
+
+				// Every truncation of valid input is an error, never a panic
+				for n := range len(tt.wkb) {
+					if wkt, err := wkbToWKT(tt.wkb[:n]); err == nil {
+						t.Errorf("wkbToWKT(%x) = %q, want error", tt.wkb[:n], wkt)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestWKBToWKTInvalid(t *testing.T) {
+	be := binary.BigEndian
+	tests := []struct {
+		name string
+		wkb  []byte
+	}{
+		{"empty", nil},
+		{"bad byte order", append([]byte{2}, geom(be, 1, 1.0, 2.0)[1:]...)},
+		{"unknown type", geom(be, 99, 1.0, 2.0)},
+		{"trailing bytes", append(geom(be, 1, 1.0, 2.0), 0)},
+		{"huge point count", geom(be, 2, uint32(math.MaxUint32), 0.0, 0.0)},
+		{"huge ring count", geom(be, 3, uint32(math.MaxUint32))},
+		{"huge member count", geom(be, 7, uint32(math.MaxUint32), geom(be, 1, 1.0, 2.0))},
+		{"truncated member", geom(be, 4, 2, geom(be, 1, 1.0, 2.0))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if wkt, err := wkbToWKT(tt.wkb); err == nil {
+				t.Errorf("wkbToWKT(%x) = %q, want error", tt.wkb, wkt)
+			}
+		})
+	}
+}
+
+// HANA returns ST_GEOMETRY values as hex-encoded WKB
+func TestSAPGeometryValue(t *testing.T) {
+	wkb := geom(binary.LittleEndian, 1, 1.0, 2.0)
+	got, err := sapTypedValue("STGEOMETRY", hex.EncodeToString(wkb), 0)
+	if err != nil || got != "POINT (1 2)" {
+		t.Errorf("sapTypedValue(STGEOMETRY) = %v, %v, want POINT (1 2)", got, err)
+	}
+
+	// Undecodable values are passed through as they are
+	got, err = sapTypedValue("STGEOMETRY", "0101", 0)
+	if err != nil || got != "0101" {
+		t.Errorf("sapTypedValue(STGEOMETRY, invalid) = %v, %v, want 0101", got, err)
+	}
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"nexus-query-agent/internal/connection"
+	"nexus-query-agent/internal/executor"
+)
+
+// probeTimeout bounds the datasource probe of a single /readyz request
+const probeTimeout = 5 * time.Second
+
+// Checker serves the agent's liveness and readiness endpoints
+type Checker struct {
+	client   *connection.NexusClient
+	inFlight func() int
+	pools    *executor.PoolManager // nil disables datasource probes
+}
+
+// NewChecker creates a checker. Pass a nil pools to skip datasource probes.
+func NewChecker(client *connection.NexusClient, inFlight func() int, pools *executor.PoolManager) *Checker {
+	return &Checker{
+		client:   client,
+		inFlight: inFlight,
+		pools:    pools,
+	}
+}
+
+// readiness is the JSON body of /readyz
+type readiness struct {
+	Ready           bool                   `json:"ready"`
+	Connected       bool                   `json:"connected"`
+	Registered      bool                   `json:"registered"`
+	Registration    string                 `json:"registration"` // disconnected, registering, registered or rejected
+	LastHeartbeat   *time.Time             `json:"last_heartbeat,omitempty"`
+	InFlightQueries int                    `json:"in_flight_queries"`
+	Datasources     []executor.ProbeResult `json:"datasources,omitempty"`
+}
+
+// Healthz reports that the process is alive
+func (c *Checker) Healthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the agent is connected to and registered with
+// Nexus Core, and optionally whether pooled datasources answer a ping
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	status := c.client.Status()
+
+	body := readiness{
+		Connected:       status.Connected,
+		Registered:      status.State == connection.StateRegistered,
+		Registration:    string(status.State),
+		InFlightQueries: c.inFlight(),
+	}
+	if !status.LastHeartbeat.IsZero() {
+		body.LastHeartbeat = &status.LastHeartbeat
+	}
+	body.Ready = status.Connected && body.Registered
+
+	if c.pools != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+		defer cancel()
+
+		body.Datasources = c.pools.Probe(ctx)
+		for _, ds := range body.Datasources {
+			if !ds.OK {
+				body.Ready = false
+			}
+		}
+	}
+
+	code := http.StatusOK
+	if !body.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, body)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,51 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Server is the agent's local HTTP listener
+type Server struct {
+	mux    *http.ServeMux
+	server *http.Server
+	logger *slog.Logger
+}
+
+// New creates a server that will listen on addr
+func New(addr string, logger *slog.Logger) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		mux: mux,
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+// Handle registers a handler for pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start serves in the background until Shutdown is called
+func (s *Server) Start() {
+	s.logger.Info("HTTP listener started", "addr", s.server.Addr)
+
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("HTTP listener failed", "error", err)
+		}
+	}()
+}
+
+// Shutdown stops the server, waiting for active requests up to ctx's deadline
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
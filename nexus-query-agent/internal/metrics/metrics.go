@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"nexus-query-agent/internal/models"
+)
+
+const namespace = "nexus_query_agent"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	// QueriesTotal counts finished requests by query type and outcome
+	QueriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queries_total",
+		Help:      "Query requests handled, by query type and outcome.",
+	}, []string{"query_type", "outcome"})
+
+	// DatasourceQueryDuration measures time spent executing against a datasource
+	DatasourceQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "datasource_query_duration_seconds",
+		Help:      "Time spent executing queries against a datasource, including connection setup.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"datasource_id", "datasource_type", "query_type"})
+
+	// RowsReturned counts rows returned by SELECT requests
+	RowsReturned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_returned_total",
+		Help:      "Rows returned by SELECT requests, by datasource.",
+	}, []string{"datasource_id", "datasource_type"})
+
+	// BytesSent counts bytes written to the Nexus Core WebSocket
+	BytesSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_bytes_sent_total",
+		Help:      "Bytes of message payload sent to Nexus Core over the WebSocket.",
+	})
+
+	// InFlight is the number of requests currently executing
+	InFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queries_in_flight",
+		Help:      "Query requests currently executing.",
+	})
+
+	// Reconnects counts reconnect attempts after the connection was lost or failed
+	Reconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconnects_total",
+		Help:      "Reconnect attempts to Nexus Core.",
+	})
+
+	// HeartbeatFailures counts heartbeats that could not be sent
+	HeartbeatFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "heartbeat_failures_total",
+		Help:      "Heartbeats that failed to send.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		QueriesTotal,
+		DatasourceQueryDuration,
+		RowsReturned,
+		BytesSent,
+		InFlight,
+		Reconnects,
+		HeartbeatFailures,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// RegisterQueueDepth exposes the scheduler's wait queue length as a gauge
+func RegisterQueueDepth(depth func() int) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Query requests waiting for a worker.",
+	}, func() float64 { return float64(depth()) }))
+}
+
+// ObserveDatasourceQuery records the time since start against a datasource.
+// It is meant to be deferred at the top of an executor method.
+func ObserveDatasourceQuery(ds *models.DatasourceInfo, queryType string, start time.Time) {
+	DatasourceQueryDuration.
+		WithLabelValues(strconv.FormatInt(ds.ID, 10), ds.Type, queryType).
+		Observe(time.Since(start).Seconds())
+}
+
+// ObserveRows records rows returned for a datasource
+func ObserveRows(ds *models.DatasourceInfo, rows int) {
+	RowsReturned.WithLabelValues(strconv.FormatInt(ds.ID, 10), ds.Type).Add(float64(rows))
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
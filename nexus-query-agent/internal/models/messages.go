@@ -1,18 +1,28 @@
 package models
 
+import "encoding/json"
+
+// ProtocolVersion is the agent protocol version advertised at registration.
+// Version 2 adds streamed results and query cancellation.
+const ProtocolVersion = 2
+
 // MessageType defines the type of WebSocket message
 type MessageType string
 
 const (
 	// Agent → Nexus
-	MessageTypeRegister  MessageType = "register"
-	MessageTypeHeartbeat MessageType = "heartbeat"
-	MessageTypeResult    MessageType = "query_result"
-	MessageTypeError     MessageType = "error"
+	MessageTypeRegister    MessageType = "register"
+	MessageTypeHeartbeat   MessageType = "heartbeat"
+	MessageTypeResult      MessageType = "query_result"
+	MessageTypeResultChunk MessageType = "query_result_chunk"
+	MessageTypeResultEnd   MessageType = "query_result_end"
+	MessageTypeCancelled   MessageType = "query_cancelled"
+	MessageTypeError       MessageType = "error"
 
 	// Nexus → Agent
 	MessageTypeRegistered   MessageType = "registered"
 	MessageTypeQueryRequest MessageType = "query_request"
+	MessageTypeCancelQuery  MessageType = "cancel_query"
 	MessageTypePing         MessageType = "ping"
 	MessageTypePong         MessageType = "pong"
 )
@@ -29,6 +39,35 @@ type RegisterMessage struct {
 	AgentName string      `json:"agent_name"`
 	AgentType string      `json:"agent_type"` // "query"
 	Token     string      `json:"token"`
+
+	Version      string             `json:"version,omitempty"`
+	Build        *BuildInfo         `json:"build,omitempty"`
+	Capabilities *AgentCapabilities `json:"capabilities,omitempty"`
+}
+
+// BuildInfo identifies the agent binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a dirty working tree
+}
+
+// AgentCapabilities tells Nexus which requests the agent can serve
+type AgentCapabilities struct {
+	ProtocolVersion int                      `json:"protocol_version"`
+	Datasources     []DatasourceCapabilities `json:"datasources"`
+	Encodings       []string                 `json:"encodings"` // Result encodings: "json", "json_stream"
+	Features        []string                 `json:"features"`  // e.g. "cancel", "keyset", "transactions", "read_only_select"
+}
+
+// DatasourceCapabilities lists what the agent supports for one datasource type
+type DatasourceCapabilities struct {
+	Type       string   `json:"type"`        // "sap", "mysql", "postgres"
+	QueryTypes []string `json:"query_types"` // e.g. "select", "insert", "update", "delete"
+	Streaming  bool     `json:"streaming"`
+	Features   []string `json:"features"` // Driver capabilities: "select", "streaming", "dml", "metadata", "procedures"
 }
 
 // RegisteredMessage is sent by Nexus after successful registration
@@ -63,11 +102,62 @@ type QueryRequest struct {
 	Type       MessageType    `json:"type"`
 	RequestID  string         `json:"request_id"`
 	Datasource DatasourceInfo `json:"datasource"` // Connection details from Nexus
-	QueryType  string         `json:"query_type"` // "select", "insert", "update", "delete"
-	Query      string         `json:"query"`
-	Params     []any          `json:"params,omitempty"` // For parameterized queries
-	Page       int            `json:"page"`
-	Limit      int            `json:"limit"`
+
+	// QueryType is "select", "insert", "update", "delete", "transaction",
+	// "bulk_insert", "upsert", "call", or a metadata type: "list_schemas",
+	// "list_tables", "list_views" or "describe_table"
+	QueryType string `json:"query_type"`
+	Query     string `json:"query"`
+	Params    []any  `json:"params,omitempty"` // Bind parameters for SELECT and DML
+	Page      int    `json:"page"`
+	Limit     int    `json:"limit"`
+	TimeoutMs int64  `json:"timeout_ms,omitempty"` // Overrides limits.query_timeout (capped by it)
+
+	// Keyset pagination: rows are ordered by OrderKey, a unique, non-null key of
+	// result columns, and Cursor (a previous next_cursor) resumes after the last
+	// row returned. Page is ignored.
+	OrderKey  []string `json:"order_key,omitempty"`
+	OrderDesc bool     `json:"order_desc,omitempty"`
+	Cursor    string   `json:"cursor,omitempty"`
+
+	// How to obtain pagination.total_rows: "exact" (default), "none",
+	// "first_page" or "cached" (reuses a recent count of the same query)
+	CountMode string `json:"count_mode,omitempty"`
+
+	// Transaction requests: statements run in order, all-or-nothing
+	Statements []Statement `json:"statements,omitempty"`
+
+	// Schema is used by list_tables, list_views, describe_table, bulk_insert,
+	// upsert and call; Table by describe_table, bulk_insert and upsert
+	Schema string `json:"schema,omitempty"` // Defaults to the session's current schema
+	Table  string `json:"table,omitempty"`  // Table or view name
+
+	// Bulk inserts and upserts: Rows are written ChunkRows (default
+	// limits.bulk_chunk_rows) at a time, each chunk in its own transaction
+	// unless Atomic is set
+	Columns []string `json:"columns,omitempty"`
+	Rows    [][]any  `json:"rows,omitempty"`
+	Atomic  bool     `json:"atomic,omitempty"`
+
+	// Upserts: columns matching existing rows. Defaults to the primary key on
+	// SAP HANA and the unique keys on MySQL; required for PostgreSQL.
+	Key []string `json:"key,omitempty"`
+
+	// Procedure calls: IN and INOUT argument values by parameter name.
+	// Omitted IN parameters take their default.
+	Procedure string         `json:"procedure,omitempty"`
+	Args      map[string]any `json:"args,omitempty"`
+
+	// Streaming: rows are sent as query_result_chunk messages followed by query_result_end
+	Stream     bool `json:"stream,omitempty"`
+	ChunkRows  int  `json:"chunk_rows,omitempty"`  // Max rows per chunk (default limits.stream_chunk_rows, or limits.bulk_chunk_rows for bulk_insert/upsert)
+	ChunkBytes int  `json:"chunk_bytes,omitempty"` // Max encoded row bytes per chunk (default limits.stream_chunk_bytes)
+}
+
+// CancelQueryMessage is sent by Nexus to stop a queued or running request
+type CancelQueryMessage struct {
+	Type      MessageType `json:"type"`
+	RequestID string      `json:"request_id"`
 }
 
 // QueryResult is sent by agent with query results
@@ -75,28 +165,126 @@ type QueryResult struct {
 	Type            MessageType      `json:"type"`
 	RequestID       string           `json:"request_id"`
 	Success         bool             `json:"success"`
-	QueryType       string           `json:"query_type,omitempty"` // "select", "insert", "update", "delete"
+	QueryType       string           `json:"query_type,omitempty"` // "select", "insert", "update", "delete", "transaction", ...
 	Data            []map[string]any `json:"data,omitempty"`
 	Columns         []ColumnInfo     `json:"columns,omitempty"`
 	Pagination      *Pagination      `json:"pagination,omitempty"`
 	AffectedRows    int64            `json:"affected_rows,omitempty"` // For DML operations
 	ExecutionTimeMs int64            `json:"execution_time_ms"`
 	Error           string           `json:"error,omitempty"`
+
+	// Transactions: per-statement results, and the index of the statement
+	// that caused the rollback
+	Statements      []StatementResult `json:"statements,omitempty"`
+	FailedStatement *int              `json:"failed_statement,omitempty"`
+
+	// Bulk inserts and upserts: chunks that were not written, and the number
+	// that were (for non-atomic writes interrupted by a cancel or timeout)
+	FailedChunks    []ChunkFailure `json:"failed_chunks,omitempty"`
+	CommittedChunks int            `json:"committed_chunks,omitempty"`
+
+	// Upserts: split of AffectedRows where the database reports it
+	// (PostgreSQL). MySQL reports neither; its AffectedRows counts an updated
+	// row twice and an unchanged row not at all.
+	InsertedRows *int64 `json:"inserted_rows,omitempty"`
+	UpdatedRows  *int64 `json:"updated_rows,omitempty"`
+
+	// Procedure calls: every result set in order, and OUT/INOUT values by name.
+	// OmittedResultSets counts result sets the call could not return (on SAP
+	// HANA, SELECTs in the body of a procedure with parameters).
+	ResultSets        []ResultSet    `json:"result_sets,omitempty"`
+	OutParams         map[string]any `json:"out_params,omitempty"`
+	OmittedResultSets int            `json:"omitted_result_sets,omitempty"`
+}
+
+// ResultSet is one result set returned by a procedure call
+type ResultSet struct {
+	Columns   []ColumnInfo     `json:"columns"`
+	Data      []map[string]any `json:"data"`
+	Truncated bool             `json:"truncated,omitempty"` // Rows beyond limits.max_rows were dropped
+}
+
+// ChunkFailure reports a bulk insert chunk that failed
+type ChunkFailure struct {
+	Index    int    `json:"index"`
+	FirstRow int    `json:"first_row"` // Index of the chunk's first row in Rows
+	Rows     int    `json:"rows"`
+	Error    string `json:"error"`
+}
+
+// Statement is one statement of a transaction request
+type Statement struct {
+	Query  string `json:"query"`
+	Params []any  `json:"params,omitempty"`
+}
+
+// StatementResult reports the outcome of one committed transaction statement
+type StatementResult struct {
+	Index        int   `json:"index"`
+	AffectedRows int64 `json:"affected_rows"`
 }
 
-// ColumnInfo describes a column in the result
+// QueryResultChunk carries a batch of rows of a streamed SELECT
+type QueryResultChunk struct {
+	Type      MessageType       `json:"type"`
+	RequestID string            `json:"request_id"`
+	Seq       int               `json:"seq"`               // 0-based chunk sequence number
+	Columns   []ColumnInfo      `json:"columns,omitempty"` // Only on the first chunk
+	Data      []json.RawMessage `json:"data"`
+}
+
+// QueryResultEnd terminates a streamed SELECT
+type QueryResultEnd struct {
+	Type            MessageType `json:"type"`
+	RequestID       string      `json:"request_id"`
+	Success         bool        `json:"success"`
+	Chunks          int         `json:"chunks"`
+	RowCount        int         `json:"row_count"`
+	Pagination      *Pagination `json:"pagination,omitempty"`
+	ExecutionTimeMs int64       `json:"execution_time_ms"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// ColumnInfo describes a column in the result, or a table column for describe_table
 type ColumnInfo struct {
 	Name     string `json:"name"`
 	Type     string `json:"type"`
 	Nullable bool   `json:"nullable"`
+
+	// JSON representation of the values when it isn't the obvious one:
+	// "decimal" (exact number), "base64", "date", "time", "timestamp"
+	// (ISO 8601 without zone), "wkt", "lob" (text) or "base64_lob". LOB
+	// values larger than limits.max_lob_bytes are sent as
+	// {"truncated": true, "data": <first max_lob_bytes>}.
+	Encoding string `json:"encoding,omitempty"`
+
+	// Filled by describe_table
+	Length     *int    `json:"length,omitempty"`    // Character and binary types
+	Precision  *int    `json:"precision,omitempty"` // Decimal types
+	Scale      *int    `json:"scale,omitempty"`     // Decimal types
+	PrimaryKey bool    `json:"primary_key,omitempty"`
+	Default    *string `json:"default,omitempty"`
+	Comment    string  `json:"comment,omitempty"`
 }
 
+// Pagination.TotalKind values
+const (
+	TotalExact     = "exact"
+	TotalEstimated = "estimated" // From the count cache; may be stale
+	TotalUnknown   = "unknown"   // Not counted; TotalRows and TotalPages are 0
+)
+
 // Pagination contains pagination info
 type Pagination struct {
-	Page       int `json:"page"`
-	Limit      int `json:"limit"`
-	TotalRows  int `json:"total_rows"`
-	TotalPages int `json:"total_pages"`
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	TotalRows  int    `json:"total_rows"`
+	TotalPages int    `json:"total_pages"`
+	TotalKind  string `json:"total_kind"` // "exact", "estimated" or "unknown"
+	HasMore    bool   `json:"has_more"`   // Another page follows
+
+	// Keyset pagination: pass back as cursor to get the next page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // ErrorMessage is sent when an error occurs
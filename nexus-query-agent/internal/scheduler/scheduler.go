@@ -0,0 +1,233 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"nexus-query-agent/internal/models"
+)
+
+// ErrQueueFull is returned by Submit when the wait queue is at capacity
+var ErrQueueFull = errors.New("agent is busy: query queue is full")
+
+// ErrStopped is returned by Submit after Stop has been called
+var ErrStopped = errors.New("agent is shutting down")
+
+// ErrDuplicateRequest is returned by Submit when a request with the same ID is
+// already queued or running; the two could not be told apart by Cancel
+var ErrDuplicateRequest = errors.New("a request with this request_id is already queued or running")
+
+// Handler executes a single query request.
+// ctx is cancelled when Nexus cancels the request or the scheduler stops.
+type Handler func(ctx context.Context, req *models.QueryRequest)
+
+// CancelState reports what Cancel found for a request
+type CancelState int
+
+const (
+	// NotFound means the request is neither queued nor running
+	NotFound CancelState = iota
+	// CancelledQueued means the request was removed before it started
+	CancelledQueued
+	// CancelledRunning means the context of a running request was cancelled
+	CancelledRunning
+)
+
+// Scheduler runs query requests on a fixed number of workers.
+// Requests wait in a bounded queue and are picked round-robin by datasource,
+// so one busy datasource cannot starve the others.
+type Scheduler struct {
+	handler   Handler
+	maxQueued int
+	ctx       context.Context
+	stop      context.CancelFunc
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queues   map[int64][]*models.QueryRequest // pending requests per datasource
+	order    []int64                          // datasources with pending requests, in turn order
+	queued   int
+	pending  map[string]bool               // IDs of queued requests
+	inflight map[string]context.CancelFunc // running requests by request ID
+	stopped  bool
+	wg       sync.WaitGroup
+}
+
+// New creates a scheduler with maxConcurrent workers and a wait queue of maxQueued requests
+func New(maxConcurrent, maxQueued int, handler Handler) *Scheduler {
+	ctx, stop := context.WithCancel(context.Background())
+	s := &Scheduler{
+		handler:   handler,
+		maxQueued: maxQueued,
+		ctx:       ctx,
+		stop:      stop,
+		queues:    make(map[int64][]*models.QueryRequest),
+		pending:   make(map[string]bool),
+		inflight:  make(map[string]context.CancelFunc),
+	}
+	s.cond = sync.NewCond(&s.mu)
+
+	for i := 0; i < maxConcurrent; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+
+	return s
+}
+
+// Submit enqueues a request without blocking.
+// It returns ErrQueueFull when the wait queue is at capacity, and
+// ErrDuplicateRequest when the request ID is already queued or running.
+func (s *Scheduler) Submit(req *models.QueryRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return ErrStopped
+	}
+	if _, running := s.inflight[req.RequestID]; running || s.pending[req.RequestID] {
+		return ErrDuplicateRequest
+	}
+	if s.queued >= s.maxQueued {
+		return ErrQueueFull
+	}
+
+	dsID := req.Datasource.ID
+	if len(s.queues[dsID]) == 0 {
+		s.order = append(s.order, dsID)
+	}
+	s.queues[dsID] = append(s.queues[dsID], req)
+	s.pending[req.RequestID] = true
+	s.queued++
+
+	s.cond.Signal()
+	return nil
+}
+
+// Cancel removes a queued request or cancels the context of a running one
+func (s *Scheduler) Cancel(requestID string) CancelState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cancel, ok := s.inflight[requestID]; ok {
+		cancel()
+		return CancelledRunning
+	}
+
+	for dsID, queue := range s.queues {
+		for i, req := range queue {
+			if req.RequestID != requestID {
+				continue
+			}
+
+			queue = append(queue[:i], queue[i+1:]...)
+			if len(queue) > 0 {
+				s.queues[dsID] = queue
+			} else {
+				delete(s.queues, dsID)
+				s.removeFromOrder(dsID)
+			}
+			delete(s.pending, requestID)
+			s.queued--
+			return CancelledQueued
+		}
+	}
+
+	return NotFound
+}
+
+// QueueDepth returns the number of requests waiting for a worker
+func (s *Scheduler) QueueDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queued
+}
+
+// Running returns the number of requests currently executing
+func (s *Scheduler) Running() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.inflight)
+}
+
+// Stop rejects new requests, drops queued ones, cancels running ones and
+// waits for their handlers to return
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.queues = make(map[int64][]*models.QueryRequest)
+	s.order = nil
+	s.pending = make(map[string]bool)
+	s.queued = 0
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	s.stop()
+	s.wg.Wait()
+}
+
+// worker executes requests until the scheduler is stopped
+func (s *Scheduler) worker() {
+	defer s.wg.Done()
+
+	for {
+		req, ctx, ok := s.next()
+		if !ok {
+			return
+		}
+
+		s.handler(ctx, req)
+
+		s.mu.Lock()
+		if cancel, ok := s.inflight[req.RequestID]; ok {
+			cancel()
+			delete(s.inflight, req.RequestID)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// next blocks until a request is available and takes it from the datasource
+// whose turn it is. It returns false once the scheduler is stopped.
+func (s *Scheduler) next() (*models.QueryRequest, context.Context, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.queued == 0 && !s.stopped {
+		s.cond.Wait()
+	}
+	if s.stopped {
+		return nil, nil, false
+	}
+
+	dsID := s.order[0]
+	s.order = s.order[1:]
+
+	queue := s.queues[dsID]
+	req := queue[0]
+	queue[0] = nil
+	if len(queue) > 1 {
+		s.queues[dsID] = queue[1:]
+		// Datasource goes to the back of the line
+		s.order = append(s.order, dsID)
+	} else {
+		delete(s.queues, dsID)
+	}
+	delete(s.pending, req.RequestID)
+	s.queued--
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.inflight[req.RequestID] = cancel
+	return req, ctx, true
+}
+
+// removeFromOrder drops a datasource from the turn order
+func (s *Scheduler) removeFromOrder(dsID int64) {
+	for i, id := range s.order {
+		if id == dsID {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			return
+		}
+	}
+}
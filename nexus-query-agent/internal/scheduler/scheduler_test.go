@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"nexus-query-agent/internal/models"
+)
+
+// testScheduler runs a scheduler whose handler reports each request as it
+// starts and then blocks until gate is closed or its context is cancelled
+type testScheduler struct {
+	*Scheduler
+	started chan string
+	done    chan string
+	gate    chan struct{}
+}
+
+func newTestScheduler(t *testing.T, maxConcurrent, maxQueued int) *testScheduler {
+	t.Helper()
+	ts := &testScheduler{
+		started: make(chan string, 100),
+		done:    make(chan string, 100),
+		gate:    make(chan struct{}),
+	}
+	ts.Scheduler = New(maxConcurrent, maxQueued, func(ctx context.Context, req *models.QueryRequest) {
+		ts.started <- req.RequestID
+		select {
+		case <-ts.gate:
+		case <-ctx.Done():
+		}
+		ts.done <- req.RequestID
+	})
+	t.Cleanup(func() {
+		ts.open()
+		ts.Stop()
+	})
+	return ts
+}
+
+// open lets every handler run to completion
+func (ts *testScheduler) open() {
+	select {
+	case <-ts.gate:
+	default:
+		close(ts.gate)
+	}
+}
+
+func request(id string, dsID int64) *models.QueryRequest {
+	return &models.QueryRequest{RequestID: id, Datasource: models.DatasourceInfo{ID: dsID}}
+}
+
+func submit(t *testing.T, s *testScheduler, id string, dsID int64) {
+	t.Helper()
+	if err := s.Submit(request(id, dsID)); err != nil {
+		t.Fatalf("Submit(%s) = %v", id, err)
+	}
+}
+
+func receive(t *testing.T, ch <-chan string) string {
+	t.Helper()
+	select {
+	case id := <-ch:
+		return id
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a request")
+		return ""
+	}
+}
+
+func TestRoundRobinAcrossDatasources(t *testing.T) {
+	s := newTestScheduler(t, 1, 10)
+
+	// a1 occupies the only worker while the rest queue up
+	submit(t, s, "a1", 1)
+	if got := receive(t, s.started); got != "a1" {
+		t.Fatalf("first request = %s, want a1", got)
+	}
+	for _, id := range []string{"a2", "a3", "a4"} {
+		submit(t, s, id, 1)
+	}
+	submit(t, s, "b1", 2)
+	submit(t, s, "b2", 2)
+
+	s.open()
+	var order []string
+	for range 5 {
+		order = append(order, receive(t, s.started))
+	}
+
+	want := []string{"a2", "b1", "a3", "b2", "a4"}
+	if !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	s := newTestScheduler(t, 1, 1)
+
+	submit(t, s, "running", 1)
+	receive(t, s.started)
+	submit(t, s, "queued", 1)
+
+	if err := s.Submit(request("rejected", 2)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit with full queue = %v, want ErrQueueFull", err)
+	}
+	if got := s.QueueDepth(); got != 1 {
+		t.Errorf("QueueDepth() = %d, want 1", got)
+	}
+}
+
+func TestDuplicateRequestID(t *testing.T) {
+	s := newTestScheduler(t, 1, 10)
+
+	submit(t, s, "running", 1)
+	receive(t, s.started)
+	submit(t, s, "queued", 1)
+
+	for _, id := range []string{"running", "queued"} {
+		if err := s.Submit(request(id, 2)); !errors.Is(err, ErrDuplicateRequest) {
+			t.Errorf("Submit(%s) again = %v, want ErrDuplicateRequest", id, err)
+		}
+	}
+
+	// Once a request is finished its ID may be reused
+	s.open()
+	receive(t, s.done)
+	receive(t, s.done)
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Running() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Submit(request("running", 1)); err != nil {
+		t.Errorf("Submit after completion = %v, want nil", err)
+	}
+}
+
+func TestCancelQueued(t *testing.T) {
+	s := newTestScheduler(t, 1, 10)
+
+	submit(t, s, "running", 1)
+	receive(t, s.started)
+	submit(t, s, "queued", 2)
+	submit(t, s, "next", 2)
+
+	if got := s.Cancel("queued"); got != CancelledQueued {
+		t.Fatalf("Cancel(queued) = %v, want CancelledQueued", got)
+	}
+	if got := s.QueueDepth(); got != 1 {
+		t.Errorf("QueueDepth() = %d, want 1", got)
+	}
+	if got := s.Cancel("queued"); got != NotFound {
+		t.Errorf("second Cancel(queued) = %v, want NotFound", got)
+	}
+
+	// The cancelled request never runs, and its ID is free again
+	s.open()
+	if got := receive(t, s.started); got != "next" {
+		t.Errorf("request after cancel = %s, want next", got)
+	}
+	if err := s.Submit(request("queued", 2)); err != nil {
+		t.Errorf("Submit of cancelled ID = %v, want nil", err)
+	}
+}
+
+func TestCancelRunning(t *testing.T) {
+	s := newTestScheduler(t, 1, 10)
+
+	submit(t, s, "running", 1)
+	receive(t, s.started)
+
+	if got := s.Cancel("running"); got != CancelledRunning {
+		t.Fatalf("Cancel(running) = %v, want CancelledRunning", got)
+	}
+	// The handler returns through its cancelled context, not the gate
+	if got := receive(t, s.done); got != "running" {
+		t.Errorf("finished request = %s, want running", got)
+	}
+	if got := s.Cancel("unknown"); got != NotFound {
+		t.Errorf("Cancel(unknown) = %v, want NotFound", got)
+	}
+}
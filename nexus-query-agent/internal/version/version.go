@@ -0,0 +1,48 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"nexus-query-agent/internal/models"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X nexus-query-agent/internal/version.Version=1.2.0 -X nexus-query-agent/internal/version.Commit=$(git rev-parse HEAD)"
+//
+// Commit and BuildTime fall back to the VCS stamp embedded by the Go toolchain.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Build returns the agent's build information
+func Build() models.BuildInfo {
+	info := models.BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	return info
+}